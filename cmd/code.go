@@ -7,16 +7,15 @@ import (
 
 	"github.com/matoval/envclone/internal/config"
 	"github.com/matoval/envclone/internal/container"
-	internalExec "github.com/matoval/envclone/internal/exec"
-	"github.com/matoval/envclone/internal/platform"
 	"github.com/matoval/envclone/internal/ssh"
 	"github.com/matoval/envclone/internal/state"
 	"github.com/spf13/cobra"
 )
 
 var codeCmd = &cobra.Command{
-	Use:   "code",
-	Short: "Open VS Code connected to the dev container via SSH",
+	Use:         "code",
+	Short:       "Open VS Code connected to the dev container via SSH",
+	Annotations: map[string]string{annotationDryRun: "true", annotationOperation: "true"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
@@ -30,18 +29,19 @@ var codeCmd = &cobra.Command{
 			return fmt.Errorf("no environment found (run 'envclone up' first): %w", err)
 		}
 
-		plat, err := platform.Detect()
+		plat, rt, err := detectRuntime(dir, env)
 		if err != nil {
 			return err
 		}
 
-		runner := &internalExec.Runner{}
 		mgr := &container.Manager{
 			Platform:   plat,
-			Runner:     runner,
+			Runtime:    rt,
 			ProjectDir: dir,
 		}
 
+		reportDrift(ctx, mgr, env)
+
 		running, err := mgr.IsRunning(ctx, env)
 		if err != nil {
 			return fmt.Errorf("checking container status: %w", err)
@@ -52,17 +52,31 @@ var codeCmd = &cobra.Command{
 
 		devContainer := fmt.Sprintf("envclone-%s-dev", env.ProjectName)
 
-		fmt.Println("Setting up SSH in dev container...")
-		if err := ssh.SetupSSH(ctx, runner, plat, devContainer, env.RemoteUser, env.SSHPort); err != nil {
-			return fmt.Errorf("setting up SSH: %w", err)
-		}
-
 		pubKey, err := ssh.FindPublicKey()
 		if err != nil {
 			return err
 		}
-		if err := ssh.InjectAuthorizedKey(ctx, runner, plat, devContainer, env.RemoteUser, pubKey); err != nil {
-			return fmt.Errorf("injecting SSH key: %w", err)
+		info, err := rt.Info(ctx)
+		if err != nil {
+			return fmt.Errorf("reading container host info: %w", err)
+		}
+		arch := ""
+		if info != nil {
+			arch = info.Arch
+		}
+		binary, err := ssh.ServerBinary(arch)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Starting SSH server in dev container...")
+		if err := ssh.StartServer(ctx, rt, devContainer, binary, env.SSHPort, pubKey, dryRun); err != nil {
+			return fmt.Errorf("setting up SSH: %w", err)
+		}
+
+		if dryRun {
+			fmt.Println("Dry run: ~/.ssh/config was not updated and VS Code was not opened.")
+			return nil
 		}
 
 		if err := ssh.WriteSSHConfig(env.ProjectName, env.SSHPort, env.RemoteUser); err != nil {
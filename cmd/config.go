@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/redact"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the project's configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print devcontainer.json as envclone reads it, with secrets masked",
+	Long: `Print the project's configuration as envclone reads it, with defaults
+filled in. Values of environment variables whose names contain PASSWORD,
+TOKEN, SECRET or KEY, or match redactPatterns, are masked, so the output
+can be shared.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := getProjectDir()
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load(dir)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(cfg, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(redact.String(string(data)))
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/container"
+	"github.com/matoval/envclone/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var restartCmd = &cobra.Command{
+	Use:         "restart [service...]",
+	Annotations: map[string]string{annotationOperation: "true"},
+	Short:       "Restart services and the dev container",
+	Long:        `Restart the named services ("dev" for the dev container), or all of them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runControl(cmd.Context(), args, "Restarted", (*container.Manager).Restart)
+	},
+}
+
+var stopCmd = &cobra.Command{
+	Use:         "stop <service...>",
+	Annotations: map[string]string{annotationOperation: "true"},
+	Short:       "Stop individual services without tearing down the environment",
+	Args:        cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runControl(cmd.Context(), args, "Stopped", (*container.Manager).Stop)
+	},
+}
+
+var startCmd = &cobra.Command{
+	Use:         "start <service...>",
+	Annotations: map[string]string{annotationOperation: "true"},
+	Short:       "Start services stopped with 'envclone stop'",
+	Args:        cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runControl(cmd.Context(), args, "Started", (*container.Manager).Start)
+	},
+}
+
+var recreateCmd = &cobra.Command{
+	Use:         "recreate <service>",
+	Annotations: map[string]string{annotationOperation: "true"},
+	Short:       "Recreate a service from the current devcontainer.json",
+	Long: `Remove a service ("dev" for the dev container) and create it again, picking
+up changes to its image, env vars or volumes without restarting the rest
+of the environment.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		mgr, env, err := newControlManager(ctx)
+		if err != nil {
+			return err
+		}
+		if mgr.Config == nil {
+			// Report why the config could not be loaded.
+			_, err := config.Load(mgr.ProjectDir)
+			return err
+		}
+
+		recreateErr := mgr.Recreate(ctx, env, args[0])
+
+		// The old container is gone even if creating the new one failed,
+		// so the state is saved either way.
+		if err := state.Save(mgr.ProjectDir, env); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+		if recreateErr != nil {
+			return recreateErr
+		}
+		fmt.Printf("Recreated %s\n", args[0])
+		return nil
+	},
+}
+
+// runControl applies a stop/start/restart action to the given targets.
+func runControl(ctx context.Context, targets []string, done string, action func(*container.Manager, context.Context, *state.Environment, []string) error) error {
+	mgr, env, err := newControlManager(ctx)
+	if err != nil {
+		return err
+	}
+	actionErr := action(mgr, ctx, env, targets)
+	// Containers stopped or started before a failure are recorded all
+	// the same.
+	if err := state.Save(mgr.ProjectDir, env); err != nil {
+		return fmt.Errorf("saving state: %w", err)
+	}
+	if actionErr != nil {
+		return actionErr
+	}
+	if len(targets) == 0 {
+		fmt.Printf("%s all containers\n", done)
+	} else {
+		for _, target := range targets {
+			fmt.Printf("%s %s\n", done, target)
+		}
+	}
+	return nil
+}
+
+// newControlManager loads the environment and, if available, the config,
+// which is needed to recreate containers and run postStartCommand.
+func newControlManager(ctx context.Context) (*container.Manager, *state.Environment, error) {
+	dir, err := getProjectDir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env, err := state.Load(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("no environment found (run 'envclone up' first): %w", err)
+	}
+
+	plat, rt, err := detectRuntime(dir, env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A recreated dev container starts from the snapshot the environment
+	// was started from.
+	mgr := &container.Manager{
+		Platform:     plat,
+		Runtime:      rt,
+		ProjectDir:   dir,
+		FromSnapshot: env.Snapshot,
+	}
+	if cfg, err := config.Load(dir); err == nil {
+		mgr.Config = cfg
+	}
+	reportDrift(ctx, mgr, env)
+	return mgr, env, nil
+}
+
+func init() {
+	rootCmd.AddCommand(restartCmd, stopCmd, startCmd, recreateCmd)
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/container"
+	"github.com/matoval/envclone/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var dataDir string
+
+var dataCmd = &cobra.Command{
+	Use:   "data",
+	Short: "Snapshot, restore and reset service data",
+}
+
+var dataSnapshotCmd = &cobra.Command{
+	Use:         "snapshot <service> [name]",
+	Annotations: map[string]string{annotationOperation: "true"},
+	Short:       "Save a service's named volumes",
+	Args:        cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		mgr, env, err := newDataManager(ctx)
+		if err != nil {
+			return err
+		}
+		dir, err := getDataDir()
+		if err != nil {
+			return err
+		}
+
+		name := time.Now().Format("20060102-150405")
+		if len(args) > 1 {
+			name = args[1]
+		}
+
+		fmt.Printf("Snapshotting %s data as %s...\n", args[0], name)
+		if err := mgr.SnapshotData(ctx, env, args[0], name, dir); err != nil {
+			return err
+		}
+		fmt.Printf("Snapshot %s saved.\n", name)
+		return nil
+	},
+}
+
+var dataRestoreCmd = &cobra.Command{
+	Use:         "restore <service> <name>",
+	Annotations: map[string]string{annotationOperation: "true"},
+	Short:       "Replace a service's named volumes with a snapshot",
+	Args:        cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		mgr, env, err := newDataManager(ctx)
+		if err != nil {
+			return err
+		}
+		dir, err := getDataDir()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Restoring %s data from %s...\n", args[0], args[1])
+		if err := mgr.RestoreData(ctx, env, args[0], args[1], dir); err != nil {
+			return err
+		}
+		fmt.Println("Data restored.")
+		return nil
+	},
+}
+
+var dataResetCmd = &cobra.Command{
+	Use:         "reset <service>",
+	Annotations: map[string]string{annotationOperation: "true"},
+	Short:       "Delete a service's named volumes and recreate it",
+	Args:        cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		mgr, env, err := newDataManager(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Resetting %s data...\n", args[0])
+		resetErr := mgr.ResetData(ctx, env, args[0])
+
+		// ResetData records the service's new container, or drops the
+		// removed one, even when it fails, so save the state either way.
+		if err := state.Save(mgr.ProjectDir, env); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+		if resetErr != nil {
+			return resetErr
+		}
+		fmt.Println("Data reset.")
+		return nil
+	},
+}
+
+var dataLsCmd = &cobra.Command{
+	Use:   "ls [service]",
+	Short: "List data snapshots",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := getDataDir()
+		if err != nil {
+			return err
+		}
+
+		service := ""
+		if len(args) > 0 {
+			service = args[0]
+		}
+
+		snapshots, err := container.DataSnapshots(dir, service)
+		if err != nil {
+			return fmt.Errorf("listing snapshots: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "SERVICE\tNAME\tSIZE\tCREATED")
+		for _, snap := range snapshots {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", snap.Service, snap.Name, formatSize(snap.Size), snap.Created.Format(time.DateTime))
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+// newDataManager loads the config and the running environment, which the
+// data commands need to find a service's volumes and container.
+func newDataManager(ctx context.Context) (*container.Manager, *state.Environment, error) {
+	dir, err := getProjectDir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env, err := state.Load(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("no environment found (run 'envclone up' first): %w", err)
+	}
+
+	plat, rt, err := detectRuntime(dir, env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mgr := &container.Manager{
+		Platform:   plat,
+		Runtime:    rt,
+		Config:     cfg,
+		ProjectDir: dir,
+	}
+	reportDrift(ctx, mgr, env)
+	return mgr, env, nil
+}
+
+// getDataDir returns --dir if given, or the project's directory under the
+// envclone state dir. --dir is made absolute, since it is bind-mounted into
+// the helper container and the runtime takes a relative path for a volume
+// name.
+func getDataDir() (string, error) {
+	if dataDir != "" {
+		dir, err := filepath.Abs(dataDir)
+		if err != nil {
+			return "", fmt.Errorf("resolving --dir: %w", err)
+		}
+		return dir, nil
+	}
+	dir, err := getProjectDir()
+	if err != nil {
+		return "", err
+	}
+	return state.DataDir(dir)
+}
+
+// formatSize renders a byte count in human-readable units.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	for _, c := range []*cobra.Command{dataSnapshotCmd, dataRestoreCmd, dataLsCmd} {
+		c.Flags().StringVar(&dataDir, "dir", "", "snapshot directory (defaults to the envclone state dir)")
+	}
+	dataCmd.AddCommand(dataSnapshotCmd, dataRestoreCmd, dataResetCmd, dataLsCmd)
+	rootCmd.AddCommand(dataCmd)
+}
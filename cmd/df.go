@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/matoval/envclone/internal/container"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var dfOutput string
+
+var dfCmd = &cobra.Command{
+	Use:   "df",
+	Short: "Show the disk space each environment takes",
+	Long: `Show the disk space envclone's environments take, by project: the dev
+image and how much of it is shared with the image it was built from, service
+images, named volumes, dev container and data snapshots, and envclone's state
+and logs. Images several projects use are counted once in the total.
+
+Volumes are measured with a throwaway container each, so df takes a moment
+with many volumes. The last line shows what 'envclone prune --images
+--volumes --state' could reclaim, or that it is unknown while some state
+cannot be read, as prune would refuse to run.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if dfOutput != "text" && dfOutput != "json" {
+			return fmt.Errorf("unknown output format %q (use text or json)", dfOutput)
+		}
+
+		envs, listErr := state.List()
+		if listErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping unreadable state: %v\n", listErr)
+		}
+		live, stale, err := pruneTargets(envs, true, 0)
+		if err != nil {
+			return err
+		}
+
+		var usages []container.ProjectUsage
+		var reclaimable int64
+		err = forEachRuntime(envs, func(rt runtime.Runtime, envs []*state.Environment) error {
+			u, err := container.DiskUsage(cmd.Context(), rt, envs)
+			if err != nil {
+				return fmt.Errorf("%s: %w", rt.Name(), err)
+			}
+			usages = append(usages, u...)
+
+			pruned, err := container.Prune(cmd.Context(), rt, live, container.PruneOptions{Images: true, Volumes: true, DryRun: true})
+			if err != nil {
+				return fmt.Errorf("%s: %w", rt.Name(), err)
+			}
+			reclaimable += reclaimableSize(u, pruned)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, env := range stale {
+			if files, err := state.DiskUsage(env.ProjectDir); err == nil {
+				reclaimable += files.Total()
+			}
+		}
+		total := totalUsage(usages)
+		// Resources of an environment whose state is unreadable would
+		// count as reclaimable, though prune refuses to remove them.
+		if listErr != nil {
+			reclaimable = -1
+		}
+
+		if dfOutput == "json" {
+			if usages == nil {
+				usages = []container.ProjectUsage{}
+			}
+			data, err := json.MarshalIndent(struct {
+				Projects    []container.ProjectUsage `json:"projects"`
+				Total       int64                    `json:"total"`
+				Reclaimable int64                    `json:"reclaimable"` // -1 if unknown
+			}{usages, total, reclaimable}, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(usages) == 0 {
+			fmt.Println("No environments.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, u := range usages {
+			dir := u.ProjectDir
+			if dir == "" {
+				dir = "no state"
+			}
+			fmt.Fprintf(w, "%s (%s, %s)\n", u.Project, dir, u.Runtime)
+			for _, img := range u.Images {
+				shared := ""
+				if img.Shared > 0 {
+					shared = fmt.Sprintf("%s shared with %s", formatSize(img.Shared), img.SharedWith)
+				}
+				fmt.Fprintf(w, "  %s image\t%s\t%s\t%s\n", img.Role, img.Name, formatSize(img.Size), shared)
+			}
+			for _, v := range u.Volumes {
+				size := "unknown"
+				if v.Size >= 0 {
+					size = formatSize(v.Size)
+				}
+				fmt.Fprintf(w, "  volume\t%s\t%s\t\n", v.Name, size)
+			}
+			if u.Files.Data > 0 {
+				fmt.Fprintf(w, "  data snapshots\t\t%s\t\n", formatSize(u.Files.Data))
+			}
+			if n := u.Files.State + u.Files.Logs; n > 0 {
+				fmt.Fprintf(w, "  state and logs\t\t%s\t\n", formatSize(n))
+			}
+			fmt.Fprintf(w, "  total\t\t%s\t\n", formatSize(u.Total()))
+			fmt.Fprintln(w)
+		}
+		w.Flush()
+
+		fmt.Printf("Total: %s\n", formatSize(total))
+		if reclaimable < 0 {
+			fmt.Println("What 'envclone prune' could reclaim is unknown while some state is unreadable.")
+		} else {
+			fmt.Printf("'envclone prune --images --volumes --state' could reclaim %s.\n", formatSize(reclaimable))
+		}
+		return nil
+	},
+}
+
+// totalUsage adds up the projects' usage, counting images that several
+// projects use once.
+func totalUsage(usages []container.ProjectUsage) int64 {
+	var total int64
+	seen := make(map[string]bool)
+	for _, u := range usages {
+		total += u.Total()
+		for _, img := range u.Images {
+			if seen[img.ID] {
+				total -= img.Unique()
+			}
+			seen[img.ID] = true
+		}
+	}
+	return total
+}
+
+// reclaimableSize adds up the space the resources prune would remove take,
+// as measured for df.
+func reclaimableSize(usages []container.ProjectUsage, pruned []container.Pruned) int64 {
+	images := make(map[string]container.ImageUsage)
+	volumes := make(map[string]int64)
+	for _, u := range usages {
+		for _, img := range u.Images {
+			images[img.ID] = img
+		}
+		for _, v := range u.Volumes {
+			volumes[v.Name] = max(v.Size, 0)
+		}
+	}
+
+	var total int64
+	seen := make(map[string]bool)
+	for _, p := range pruned {
+		switch p.Kind {
+		case container.PruneImage:
+			if img, ok := images[p.ID]; ok && !seen[p.ID] {
+				seen[p.ID] = true
+				total += img.Unique()
+			}
+		case container.PruneVolume:
+			total += volumes[p.Name]
+		}
+	}
+	return total
+}
+
+func init() {
+	dfCmd.Flags().StringVarP(&dfOutput, "output", "o", "text", "output format: text or json")
+	rootCmd.AddCommand(dfCmd)
+}
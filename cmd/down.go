@@ -4,15 +4,16 @@ import (
 	"fmt"
 
 	"github.com/matoval/envclone/internal/container"
-	"github.com/matoval/envclone/internal/exec"
-	"github.com/matoval/envclone/internal/platform"
 	"github.com/matoval/envclone/internal/state"
 	"github.com/spf13/cobra"
 )
 
+var downVolumes bool
+
 var downCmd = &cobra.Command{
-	Use:   "down",
-	Short: "Stop the dev environment",
+	Use:         "down",
+	Short:       "Stop the dev environment",
+	Annotations: map[string]string{annotationDryRun: "true", annotationOperation: "true"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
@@ -26,21 +27,24 @@ var downCmd = &cobra.Command{
 			return fmt.Errorf("no environment found: %w", err)
 		}
 
-		plat, err := platform.Detect()
+		plat, rt, err := detectRuntime(dir, env)
 		if err != nil {
 			return err
 		}
 
-		runner := &exec.Runner{}
 		mgr := &container.Manager{
 			Platform:   plat,
-			Runner:     runner,
+			Runtime:    rt,
 			ProjectDir: dir,
 		}
 
-		if err := mgr.Down(ctx, env); err != nil {
+		if err := mgr.Down(ctx, env, downVolumes); err != nil {
 			return err
 		}
+		if dryRun {
+			fmt.Println("Dry run: no changes were made.")
+			return nil
+		}
 
 		if err := state.Remove(dir); err != nil {
 			return fmt.Errorf("removing state: %w", err)
@@ -52,5 +56,6 @@ var downCmd = &cobra.Command{
 }
 
 func init() {
+	downCmd.Flags().BoolVar(&downVolumes, "volumes", false, "also remove the project's named volumes")
 	rootCmd.AddCommand(downCmd)
 }
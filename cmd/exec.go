@@ -1,19 +1,37 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	osexec "os/exec"
 
 	"github.com/matoval/envclone/internal/container"
-	"github.com/matoval/envclone/internal/exec"
-	"github.com/matoval/envclone/internal/platform"
+	"github.com/matoval/envclone/internal/runtime"
 	"github.com/matoval/envclone/internal/state"
 	"github.com/spf13/cobra"
 )
 
+var (
+	execService string
+	execOpts    runtime.ExecOptions
+	execEnvFile string
+)
+
 var execCmd = &cobra.Command{
 	Use:   "exec [command...]",
 	Short: "Execute a command in the dev container",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Execute a command in the dev container, or in a service with --service.
+
+In the dev container the command runs as remoteUser, in the directory that
+matches the current directory when it is inside the workspace. A TTY is
+allocated when stdin is a terminal, and the command's exit code becomes
+envclone's exit code.`,
+	Example: `  envclone exec go test ./...
+  envclone exec --service postgres -- psql -U postgres`,
+	Args:          cobra.MinimumNArgs(1),
+	SilenceErrors: true,
+	SilenceUsage:  true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
@@ -27,22 +45,59 @@ var execCmd = &cobra.Command{
 			return fmt.Errorf("no environment found (run 'envclone up' first): %w", err)
 		}
 
-		plat, err := platform.Detect()
+		plat, rt, err := detectRuntime(dir, env)
 		if err != nil {
 			return err
 		}
 
-		runner := &exec.Runner{}
 		mgr := &container.Manager{
 			Platform:   plat,
-			Runner:     runner,
+			Runtime:    rt,
 			ProjectDir: dir,
 		}
 
-		return mgr.Exec(ctx, env, args)
+		reportDrift(ctx, mgr, env)
+
+		opts := execOpts
+		if execEnvFile != "" {
+			fileEnv, err := container.ParseEnvFile(execEnvFile)
+			if err != nil {
+				return err
+			}
+			// -e flags take precedence, so they go last.
+			opts.Env = append(fileEnv, opts.Env...)
+		}
+		if opts.Workdir == "" && execService == "" {
+			if cwd, err := os.Getwd(); err == nil {
+				opts.Workdir, _ = container.MapWorkdir(env, cwd)
+			}
+		}
+		opts.Interactive = isTerminal(os.Stdin) || isPipe(os.Stdin)
+		opts.TTY = isTerminal(os.Stdin) && isTerminal(os.Stdout)
+
+		err = mgr.Exec(ctx, env, execService, opts, args)
+		var exitErr *osexec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitCodeError{code: exitCode(exitErr)}
+		}
+		return err
 	},
 }
 
+// isPipe reports whether f is a pipe or a regular file, i.e. whether there
+// is input worth forwarding to the container.
+func isPipe(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && (fi.Mode()&os.ModeNamedPipe != 0 || fi.Mode().IsRegular())
+}
+
 func init() {
+	// Flags after the command belong to the command, not to envclone.
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().StringVar(&execService, "service", "", "run in a service container instead of the dev container")
+	execCmd.Flags().StringVarP(&execOpts.User, "user", "u", "", "user to run as (defaults to remoteUser in the dev container)")
+	execCmd.Flags().StringVarP(&execOpts.Workdir, "workdir", "w", "", "working directory inside the container")
+	execCmd.Flags().StringArrayVarP(&execOpts.Env, "env", "e", nil, "set an environment variable (KEY=VALUE)")
+	execCmd.Flags().StringVar(&execEnvFile, "env-file", "", "read environment variables from a file")
 	rootCmd.AddCommand(execCmd)
 }
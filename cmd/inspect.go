@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/matoval/envclone/internal/redact"
+	"github.com/matoval/envclone/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var inspectOutput string
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Show what envclone recorded about the environment",
+	Long: `Show the environment's state as envclone recorded it during up: when it
+was created and started, which devcontainer.json it was created from, its
+containers and the digests of their images, published ports and the outcome
+of the lifecycle commands. --output json prints the state file itself.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := getProjectDir()
+		if err != nil {
+			return err
+		}
+
+		env, err := state.Load(dir)
+		if err != nil {
+			return fmt.Errorf("no environment found (run 'envclone up' first): %w", err)
+		}
+
+		switch inspectOutput {
+		case "json":
+			data, err := json.MarshalIndent(env, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(redact.String(string(data)))
+		case "text":
+			printEnvironment(env)
+		default:
+			return fmt.Errorf("unknown output format %q (use text or json)", inspectOutput)
+		}
+		return nil
+	},
+}
+
+func printEnvironment(env *state.Environment) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Project:\t%s (%s)\n", env.ProjectName, env.ProjectDir)
+	status := env.Status
+	if env.Failed() {
+		status = fmt.Sprintf("%s: %s", status, redact.String(env.Error))
+	}
+	fmt.Fprintf(w, "Status:\t%s\n", status)
+	fmt.Fprintf(w, "Runtime:\t%s\n", env.Runtime)
+	fmt.Fprintf(w, "Created:\t%s\n", formatTime(env.CreatedAt))
+	fmt.Fprintf(w, "Started:\t%s\n", formatTime(env.StartedAt))
+	if env.ConfigFile != "" {
+		fmt.Fprintf(w, "Config:\t%s (%s)\n", env.ConfigFile, env.ConfigHash)
+	}
+	fmt.Fprintf(w, "Workspace:\t%s -> %s\n", env.WorkspaceFolder, env.WorkspaceMount)
+	fmt.Fprintf(w, "Ports:\t%s\n", strings.Join(env.Ports, ", "))
+	fmt.Fprintf(w, "User:\t%s (%s)\n", env.RemoteUser, env.Shell)
+	if env.EnvcloneVersion != "" {
+		fmt.Fprintf(w, "envclone:\t%s (state schema %d)\n", env.EnvcloneVersion, env.SchemaVersion)
+	}
+	w.Flush()
+
+	if len(env.Containers) > 0 {
+		fmt.Println("\nContainers:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tROLE\tID\tIMAGE\tIMAGE ID")
+		for _, c := range env.Containers {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", c.Name, c.Role, shortID(c.ID), c.Image, shortID(c.ImageID))
+		}
+		w.Flush()
+	}
+
+	if len(env.FeatureDigests) > 0 {
+		fmt.Println("\nFeatures:")
+		for _, id := range sortedKeys(env.FeatureDigests) {
+			fmt.Printf("  %s (%s)\n", id, env.FeatureDigests[id])
+		}
+	}
+
+	if len(env.Lifecycle) > 0 {
+		fmt.Println("\nLifecycle:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		names := make([]string, 0, len(env.Lifecycle))
+		for name := range env.Lifecycle {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			rec := env.Lifecycle[name]
+			outcome := "completed"
+			if rec.Error != "" {
+				outcome = "failed: " + redact.String(rec.Error)
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", name, formatTime(rec.Finished), outcome)
+		}
+		w.Flush()
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+// shortID shortens a container ID or image digest for display.
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	inspectCmd.Flags().StringVarP(&inspectOutput, "output", "o", "text", "output format: text or json")
+	rootCmd.AddCommand(inspectCmd)
+}
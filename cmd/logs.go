@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/matoval/envclone/internal/container"
+	"github.com/matoval/envclone/internal/logging"
+	"github.com/matoval/envclone/internal/state"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	logOpts     container.LogOptions
+	logEnvclone bool
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [service...]",
+	Short: "Show logs of the dev container and services",
+	Long: `Show logs of the environment's containers, each line prefixed with its
+source. Name services, "dev" for the dev container or "lifecycle" for the
+output of postCreateCommand and postStartCommand captured during up.
+With no arguments, everything is shown.
+
+With --envclone, show envclone's own transcript of the last operation that
+changed the environment (up, down, restart, ...): every runtime command it
+ran, with its output. Attach it to bug reports.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		dir, err := getProjectDir()
+		if err != nil {
+			return err
+		}
+
+		if logEnvclone {
+			path, err := state.OperationLog(dir)
+			if err != nil {
+				return err
+			}
+			return logging.WriteLastOperation(path, os.Stdout)
+		}
+
+		env, err := state.Load(dir)
+		if err != nil {
+			return fmt.Errorf("no environment found (run 'envclone up' first): %w", err)
+		}
+
+		plat, rt, err := detectRuntime(dir, env)
+		if err != nil {
+			return err
+		}
+
+		mgr := &container.Manager{
+			Platform:   plat,
+			Runtime:    rt,
+			ProjectDir: dir,
+		}
+
+		reportDrift(ctx, mgr, env)
+
+		logOpts.Color = isTerminal(os.Stdout)
+		return mgr.Logs(ctx, env, args, logOpts, os.Stdout)
+	},
+}
+
+// isTerminal reports whether f is attached to a terminal. Other character
+// devices, such as /dev/null, are not terminals.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+func init() {
+	logsCmd.Flags().BoolVarP(&logOpts.Follow, "follow", "f", false, "follow log output")
+	logsCmd.Flags().StringVar(&logOpts.Since, "since", "", "show logs since a timestamp or relative duration (e.g. 10m)")
+	logsCmd.Flags().IntVarP(&logOpts.Tail, "tail", "n", -1, "number of lines to show from the end of each log")
+	logsCmd.Flags().BoolVarP(&logOpts.Timestamps, "timestamps", "t", false, "show timestamps")
+	logsCmd.Flags().BoolVar(&logEnvclone, "envclone", false, "show envclone's transcript of the last operation")
+	rootCmd.AddCommand(logsCmd)
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/matoval/envclone/internal/container"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lsAll    bool
+	lsOutput string
+)
+
+var lsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List environments on this machine",
+	Long: `List the environments envclone knows about, from its state directory and
+from the envclone.project labels of containers, with each one's project
+directory, status, uptime, ports, resource usage and whether devcontainer.json
+changed since the last up. Containers of projects envclone has no state for
+are listed as orphaned.
+
+Only environments with running containers are listed unless --all is given.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if lsOutput != "text" && lsOutput != "json" {
+			return fmt.Errorf("unknown output format %q (use text or json)", lsOutput)
+		}
+
+		summaries, err := listEnvironments(cmd)
+		if err != nil {
+			return err
+		}
+		if !lsAll {
+			var running []container.Summary
+			for _, s := range summaries {
+				if s.Running > 0 {
+					running = append(running, s)
+				}
+			}
+			summaries = running
+		}
+
+		if lsOutput == "json" {
+			if summaries == nil {
+				summaries = []container.Summary{}
+			}
+			data, err := json.MarshalIndent(summaries, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(summaries) == 0 {
+			if lsAll {
+				fmt.Println("No environments.")
+			} else {
+				fmt.Println("No running environments (use --all to list stopped ones).")
+			}
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PROJECT\tDIRECTORY\tSTATUS\tUPTIME\tPORTS\tCPU\tMEMORY\tCONFIG")
+		for _, s := range summaries {
+			dir := s.ProjectDir
+			if dir == "" {
+				dir = "-"
+			}
+			cpu, mem := "-", "-"
+			if s.Usage != nil {
+				cpu = fmt.Sprintf("%.1f%%", s.Usage.CPUPercent)
+				mem = formatSize(int64(s.Usage.MemoryBytes))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				s.Project, dir, summaryStatus(s), formatUptime(s.StartedAt),
+				strings.Join(s.Ports, ", "), cpu, mem, s.Config)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+// listEnvironments summarizes the environments of every runtime.
+func listEnvironments(cmd *cobra.Command) ([]container.Summary, error) {
+	envs, err := state.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: skipping unreadable state: %v\n", err)
+	}
+
+	var summaries []container.Summary
+	err = forEachRuntime(envs, func(rt runtime.Runtime, envs []*state.Environment) error {
+		s, err := container.Summarize(cmd.Context(), rt, envs)
+		if err != nil {
+			return fmt.Errorf("listing %s containers: %w", rt.Name(), err)
+		}
+		summaries = append(summaries, s...)
+		return nil
+	})
+	return summaries, err
+}
+
+// summaryStatus shows how many containers run when not all of them do.
+func summaryStatus(s container.Summary) string {
+	if s.Status == container.SummaryDegraded || s.Status == container.SummaryOrphaned {
+		return fmt.Sprintf("%s (%d/%d running)", s.Status, s.Running, s.Containers)
+	}
+	return s.Status
+}
+
+// formatUptime renders the time since t coarsely, e.g. "3d4h" or "12m".
+func formatUptime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := time.Since(t)
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
+
+func init() {
+	lsCmd.Flags().BoolVarP(&lsAll, "all", "a", false, "also list stopped, failed and orphaned environments without running containers")
+	lsCmd.Flags().StringVarP(&lsOutput, "output", "o", "text", "output format: text or json")
+	rootCmd.AddCommand(lsCmd)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/container"
+	"github.com/matoval/envclone/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show what 'envclone up' would change",
+	Long: `Compare devcontainer.json with the running environment and show which
+containers 'envclone up' would create, recreate, keep or remove.`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{annotationDryRun: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		dir, err := getProjectDir()
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load(dir)
+		if err != nil {
+			return err
+		}
+
+		// Plan against the runtime the environment runs on, if any.
+		env, _ := state.Load(dir)
+		plat, rt, err := detectRuntime(dir, env)
+		if err != nil {
+			return err
+		}
+
+		mgr := &container.Manager{
+			Platform:   plat,
+			Runtime:    rt,
+			Config:     cfg,
+			ProjectDir: dir,
+		}
+		if env != nil {
+			mgr.FromSnapshot = env.Snapshot
+		}
+
+		plan, err := mgr.Plan(ctx)
+		if err != nil {
+			return err
+		}
+
+		if plan.Empty() {
+			fmt.Println("No changes. The environment matches devcontainer.json.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, c := range plan.Changes {
+			action := c.Action
+			if c.Reason != "" {
+				action = fmt.Sprintf("%s (%s)", c.Action, c.Reason)
+			}
+			fmt.Fprintf(w, "  %s %s\t%s\t%s\n", planSymbol(c.Action), c.Name, c.Role, action)
+		}
+		w.Flush()
+
+		fmt.Printf("\nPlan: %d to create, %d to recreate, %d to remove, %d unchanged.\n",
+			plan.Count(container.ActionCreate), plan.Count(container.ActionRecreate),
+			plan.Count(container.ActionRemove), plan.Count(container.ActionKeep))
+		return nil
+	},
+}
+
+// planSymbol marks a change the way terraform plan does.
+func planSymbol(action string) string {
+	switch action {
+	case container.ActionCreate:
+		return "+"
+	case container.ActionRecreate:
+		return "~"
+	case container.ActionRemove:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+}
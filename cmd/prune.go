@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matoval/envclone/internal/container"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneImages     bool
+	pruneVolumes    bool
+	pruneState      bool
+	pruneOlderThan  string
+	pruneUnreadable bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:         "prune",
+	Annotations: map[string]string{annotationDryRun: "true"},
+	Short:       "Remove envclone resources no environment uses",
+	Long: `Remove what envclone created for projects that no longer have an
+environment: containers left behind by crashed or interrupted runs, and with
+--images the images it built and snapshots it committed, with --volumes the
+projects' named volumes. --state also forgets environments whose project
+directory was deleted, along with their logs and data snapshots, and prunes
+their resources.
+
+Resources are found through their envclone labels and names and the state
+directory. Environments an envclone command is changing right now are left
+alone. --older-than limits pruning to resources created before then, e.g.
+30d, 2w or 12h.
+
+A state file that cannot be read, e.g. one written by a newer envclone,
+stops prune: its environment's containers and volumes would look unused.
+--ignore-unreadable-state prunes anyway.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := container.PruneOptions{Images: pruneImages, Volumes: pruneVolumes, DryRun: dryRun}
+		if pruneOlderThan != "" {
+			age, err := parseAge(pruneOlderThan)
+			if err != nil {
+				return err
+			}
+			opts.OlderThan = age
+		}
+
+		envs, err := listForPrune(pruneUnreadable)
+		if err != nil {
+			return err
+		}
+		live, stale, err := pruneTargets(envs, pruneState, opts.OlderThan)
+		if err != nil {
+			return err
+		}
+
+		var pruned []container.Pruned
+		var errs []error
+		err = forEachRuntime(envs, func(rt runtime.Runtime, _ []*state.Environment) error {
+			p, err := container.Prune(cmd.Context(), rt, live, opts)
+			pruned = append(pruned, p...)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", rt.Name(), err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, env := range stale {
+			size := int64(-1)
+			if usage, err := state.DiskUsage(env.ProjectDir); err == nil {
+				size = usage.Total()
+			}
+			if !dryRun {
+				if err := state.Purge(env.ProjectDir); err != nil {
+					errs = append(errs, fmt.Errorf("removing state of %s: %w", env.ProjectDir, err))
+					continue
+				}
+			}
+			pruned = append(pruned, container.Pruned{Kind: container.PruneState, Name: env.ProjectDir, Project: env.ProjectName, Size: size})
+		}
+
+		printPruned(pruned)
+		return errors.Join(errs...)
+	},
+}
+
+// listForPrune loads the state of every environment. An unreadable state
+// file may belong to a live environment, which prune would then take for
+// orphaned, so it is an error unless ignoreUnreadable is set.
+func listForPrune(ignoreUnreadable bool) ([]*state.Environment, error) {
+	envs, err := state.List()
+	if err == nil {
+		return envs, nil
+	}
+	if !ignoreUnreadable {
+		return nil, fmt.Errorf("reading state: %w\nThe resources of these environments would look unused. Fix or remove the files, or pass --ignore-unreadable-state to prune anyway.", err)
+	}
+	fmt.Fprintf(os.Stderr, "Warning: ignoring unreadable state: %v\n", err)
+	return envs, nil
+}
+
+// pruneTargets splits environments into the projects prune must leave
+// alone and, if withState is set, stale environments whose project
+// directory was deleted. Projects an envclone command is changing right
+// now are live, even before they have state.
+func pruneTargets(envs []*state.Environment, withState bool, olderThan time.Duration) (live []string, stale []*state.Environment, err error) {
+	busy, err := state.Busy()
+	if err != nil {
+		return nil, nil, err
+	}
+	isBusy := make(map[string]bool)
+	for _, dir := range busy {
+		isBusy[dir] = true
+		live = append(live, filepath.Base(dir))
+	}
+
+	for _, env := range envs {
+		if withState && !isBusy[env.ProjectDir] && projectGone(env) && oldEnough(env, olderThan) {
+			stale = append(stale, env)
+		} else {
+			live = append(live, env.ProjectName)
+		}
+	}
+	return live, stale, nil
+}
+
+func printPruned(pruned []container.Pruned) {
+	if len(pruned) == 0 {
+		fmt.Println("Nothing to prune.")
+		return
+	}
+	verb, total := "Removed", "Reclaimed"
+	if dryRun {
+		verb, total = "Would remove", "Would reclaim"
+	}
+	var reclaimed int64
+	unknown := 0
+	for _, p := range pruned {
+		size := ""
+		if p.Size >= 0 {
+			reclaimed += p.Size
+			size = fmt.Sprintf(" (%s)", formatSize(p.Size))
+		} else {
+			unknown++
+		}
+		fmt.Printf("%s %s %s%s\n", verb, p.Kind, p.Name, size)
+	}
+	fmt.Printf("\n%s %s", total, formatSize(reclaimed))
+	if unknown > 0 {
+		fmt.Printf(", plus %d item(s) of unknown size", unknown)
+	}
+	fmt.Println(".")
+}
+
+// projectGone reports whether an environment's project directory was
+// deleted.
+func projectGone(env *state.Environment) bool {
+	if env.ProjectDir == "" {
+		return false
+	}
+	_, err := os.Stat(env.ProjectDir)
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// oldEnough reports whether an environment was last started more than age
+// ago. Environments with no recorded start count as old.
+func oldEnough(env *state.Environment, age time.Duration) bool {
+	started := env.StartedAt
+	if started.IsZero() {
+		started = env.CreatedAt
+	}
+	return age == 0 || started.IsZero() || time.Since(started) > age
+}
+
+// parseAge parses a duration such as "12h", also accepting days ("30d")
+// and weeks ("2w").
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			if v, err := strconv.Atoi(n); err == nil && v >= 0 {
+				return time.Duration(v) * unit, nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 30d, 2w or 12h)", s)
+	}
+	return d, nil
+}
+
+func init() {
+	pruneCmd.Flags().BoolVar(&pruneImages, "images", false, "also remove images envclone built or committed for pruned projects")
+	pruneCmd.Flags().BoolVar(&pruneVolumes, "volumes", false, "also remove the named volumes of pruned projects")
+	pruneCmd.Flags().BoolVar(&pruneState, "state", false, "also forget environments whose project directory was deleted, with their logs and data snapshots")
+	pruneCmd.Flags().BoolVar(&pruneUnreadable, "ignore-unreadable-state", false, "prune even if some environments' state cannot be read, treating their resources as unused")
+	pruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "only prune resources created before this long ago, e.g. 30d")
+	rootCmd.AddCommand(pruneCmd)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/state"
+)
+
+func TestListForPruneUnreadableState(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := state.Save("/src/web", &state.Environment{ProjectName: "web", ProjectDir: "/src/web"}); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := state.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A corrupt file, and one a newer envclone wrote for a running project.
+	files := map[string]string{
+		"corrupt.json": `{"projectName": "db"`,
+		"newer.json":   `{"schemaVersion": 999, "projectName": "api", "projectDir": "/src/api"}`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Their containers and volumes would look orphaned, so prune and the
+	// reclaimable space df reports must not go by the readable state alone.
+	envs, err := listForPrune(false)
+	if err == nil {
+		t.Fatalf("listForPrune = %d environments, want an error", len(envs))
+	}
+	for name := range files {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error does not name %s: %v", name, err)
+		}
+	}
+
+	// prune itself stops before looking for candidates.
+	if err := pruneCmd.RunE(pruneCmd, nil); err == nil || !strings.Contains(err.Error(), "--ignore-unreadable-state") {
+		t.Errorf("prune = %v, want it to refuse", err)
+	}
+
+	envs, err = listForPrune(true)
+	if err != nil || len(envs) != 1 || envs[0].ProjectName != "web" {
+		t.Errorf("listForPrune(ignoring unreadable state) = %v, %v; want only web", envs, err)
+	}
+}
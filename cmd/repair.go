@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/container"
+	"github.com/matoval/envclone/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var repairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Recreate missing or stopped containers of the environment",
+	Long: `Bring the environment back after containers were removed or stopped
+outside envclone, e.g. by 'nerdctl rm' or a reboot. Missing, stopped and
+replaced containers are recreated from devcontainer.json and containers
+that are not part of the environment are removed. As with 'envclone up',
+containers whose configuration changed are recreated too. An environment
+whose last up failed is brought up again.`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{annotationDryRun: "true", annotationOperation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		dir, err := getProjectDir()
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load(dir)
+		if err != nil {
+			return err
+		}
+
+		env, err := state.Load(dir)
+		if err != nil {
+			return fmt.Errorf("no environment found (run 'envclone up' first): %w", err)
+		}
+
+		plat, rt, err := detectRuntime(dir, env)
+		if err != nil {
+			return err
+		}
+		if err := plat.EnsureRuntime(ctx); err != nil {
+			return fmt.Errorf("runtime not ready: %w", err)
+		}
+
+		mgr := &container.Manager{
+			Platform:     plat,
+			Runtime:      rt,
+			Config:       cfg,
+			ProjectDir:   dir,
+			Previous:     env,
+			FromSnapshot: env.Snapshot,
+		}
+
+		drift, err := mgr.Drift(ctx, env)
+		if err != nil {
+			return err
+		}
+		if len(drift) == 0 && !env.Failed() {
+			fmt.Println("Nothing to repair. The environment matches its recorded state.")
+			return nil
+		}
+		if env.Failed() {
+			fmt.Printf("  the last up failed: %s\n", env.Error)
+		}
+		for _, d := range drift {
+			fmt.Printf("  %s\n", d)
+		}
+
+		// Up recreates stopped containers, including ones stopped on
+		// purpose, and carries over the records of the others.
+		for i := range env.Containers {
+			env.Containers[i].Stopped = false
+		}
+
+		repaired, err := mgr.Up(ctx)
+		if dryRun {
+			if err == nil {
+				fmt.Println("Dry run: no changes were made.")
+			}
+			return err
+		}
+		if err != nil {
+			// Up may have removed containers before failing.
+			if repaired != nil {
+				if saveErr := state.Save(dir, repaired); saveErr != nil {
+					return fmt.Errorf("%w (saving failed state: %v)", err, saveErr)
+				}
+			}
+			return err
+		}
+		if err := state.Save(dir, repaired); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+		fmt.Println("Environment repaired.")
+		return nil
+	},
+}
+
+// reportDrift warns on stderr when the environment's containers no longer
+// match its recorded state. It runs before commands that use an existing
+// environment; failing to check is not worth failing the command over.
+func reportDrift(ctx context.Context, mgr *container.Manager, env *state.Environment) {
+	drift, err := mgr.Drift(ctx, env)
+	if err != nil {
+		slog.Debug("checking for drift failed", "err", err)
+		return
+	}
+	if len(drift) == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Warning: the environment has drifted from its recorded state:")
+	for _, d := range drift {
+		fmt.Fprintf(os.Stderr, "  %s\n", d)
+	}
+	fmt.Fprintln(os.Stderr, "Run 'envclone repair' to fix it.")
+}
+
+func init() {
+	rootCmd.AddCommand(repairCmd)
+}
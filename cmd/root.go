@@ -1,29 +1,175 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/logging"
+	"github.com/matoval/envclone/internal/redact"
+	"github.com/matoval/envclone/internal/state"
+	"github.com/matoval/envclone/internal/version"
 	"github.com/spf13/cobra"
 )
 
-var projectDir string
+var (
+	projectDir string
+	dryRun     bool
+	verbose    int
+	quiet      bool
+	logFormat  string
+
+	operationStart time.Time
+	operationLock  *state.Lock
+)
+
+// Command annotations.
+const (
+	// annotationDryRun marks commands that support --dry-run.
+	annotationDryRun = "envclone/dry-run"
+	// annotationOperation marks commands that change the environment.
+	// They hold the project's lock while they run, and the runtime
+	// commands they run are recorded in the project's operation log,
+	// shown by 'envclone logs --envclone'.
+	annotationOperation = "envclone/operation"
+)
 
 var rootCmd = &cobra.Command{
-	Use:   "envclone",
-	Short: "Containerized dev environments with sidecar services",
-	Long:  `envclone provides containerized dev shells with sidecar services, host filesystem mounts, VS Code SSH integration, and devcontainer.json compatibility.`,
+	Use:     "envclone",
+	Short:   "Containerized dev environments with sidecar services",
+	Long:    `envclone provides containerized dev shells with sidecar services, host filesystem mounts, VS Code SSH integration, and devcontainer.json compatibility.`,
+	Version: version.String(),
+	// Execute prints errors itself, with secrets masked.
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := logging.Setup(logLevel(), logFormat); err != nil {
+			return err
+		}
+		if dryRun && cmd.Annotations[annotationDryRun] == "" {
+			return fmt.Errorf("--dry-run is not supported by 'envclone %s'", cmd.Name())
+		}
+		configureRedaction()
+		if cmd.Annotations[annotationOperation] != "" && !dryRun {
+			if err := lockProject(); err != nil {
+				return err
+			}
+			startOperation(cmd, args)
+		}
+		return nil
+	},
+}
+
+// logLevel maps -v and -q to the level of diagnostics shown on stderr:
+// -v shows the runtime commands envclone runs, -vv also their output.
+func logLevel() slog.Level {
+	switch {
+	case quiet:
+		return slog.LevelError
+	case verbose >= 2:
+		return logging.LevelTrace
+	case verbose == 1:
+		return slog.LevelDebug
+	default:
+		return slog.LevelWarn
+	}
+}
+
+// configureRedaction adds the project's redactPatterns to the names whose
+// values are masked. Commands that run outside a project use the defaults.
+func configureRedaction() {
+	dir, err := getProjectDir()
+	if err != nil {
+		return
+	}
+	if cfg, err := config.Load(dir); err == nil {
+		redact.AddPatterns(cfg.RedactPatterns...)
+	}
+}
+
+// lockProject takes the project's lock for a command that changes the
+// environment, failing at once if another envclone holds it. Read-only
+// commands do not take it, so they never wait.
+func lockProject() error {
+	dir, err := getProjectDir()
+	if err != nil {
+		return err
+	}
+	lock, err := state.AcquireLock(dir)
+	if err != nil {
+		return err
+	}
+	operationLock = lock
+	return nil
+}
+
+// startOperation starts recording cmd in the project's operation log.
+func startOperation(cmd *cobra.Command, args []string) {
+	dir, err := getProjectDir()
+	if err != nil {
+		return
+	}
+	path, err := state.OperationLog(dir)
+	if err != nil {
+		slog.Warn("operation log unavailable", "err", err)
+		return
+	}
+	operationStart = time.Now()
+	logging.StartOperation(path, strings.Join(append([]string{cmd.CommandPath()}, args...), " "))
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	// Cancel the command context on Ctrl-C so long-running operations such
+	// as up can roll back instead of being killed halfway.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
+	logging.EndOperation(err, time.Since(operationStart))
+	operationLock.Release()
+	if err != nil {
+		var codeErr exitCodeError
+		if errors.As(err, &codeErr) {
+			os.Exit(codeErr.code)
+		}
+		fmt.Fprintln(os.Stderr, "Error:", redact.String(err.Error()))
 		os.Exit(1)
 	}
 }
 
+// exitCodeError makes envclone exit with a specific code without printing
+// anything, for commands whose own output already explains the failure.
+type exitCodeError struct {
+	code int
+}
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// exitCode returns the exit code of a finished process, using the shell
+// convention of 128+signal for processes killed by a signal.
+func exitCode(err *exec.ExitError) int {
+	if status, ok := err.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return err.ExitCode()
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVar(&projectDir, "project-dir", "", "project directory (defaults to current directory)")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print the runtime commands that would change the environment instead of running them")
+	rootCmd.PersistentFlags().CountVarP(&verbose, "verbose", "v", "show the runtime commands envclone runs (-vv: and their output)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only show errors")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logging.FormatText, "format of diagnostics on stderr: text or json")
+	rootCmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
 }
 
 func getProjectDir() (string, error) {
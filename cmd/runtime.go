@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/exec"
+	"github.com/matoval/envclone/internal/platform"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+// detectRuntime selects the platform and container runtime for a project.
+// An existing environment keeps the runtime it was created with; otherwise
+// ENVCLONE_RUNTIME, then "runtime" in devcontainer.json, then
+// auto-detection decide.
+func detectRuntime(dir string, env *state.Environment) (platform.Platform, runtime.Runtime, error) {
+	preferred := os.Getenv("ENVCLONE_RUNTIME")
+	if env != nil && env.Runtime != "" {
+		preferred = env.Runtime
+	} else if preferred == "" {
+		if cfg, err := config.Load(dir); err == nil {
+			preferred = cfg.Runtime
+		}
+	}
+
+	plat, err := platform.Detect(preferred)
+	if err != nil {
+		return nil, nil, err
+	}
+	rt, err := runtime.New(plat, &exec.Local{DryRun: dryRun})
+	if err != nil {
+		return nil, nil, err
+	}
+	return plat, rt, nil
+}
+
+// forEachRuntime calls fn with each runtime environments were created
+// with and the environments using it, in order of runtime name. The
+// default runtime is included even without environments, as it may hold
+// containers envclone has no state for. Runtimes that are not available
+// are skipped with a warning.
+func forEachRuntime(envs []*state.Environment, fn func(rt runtime.Runtime, envs []*state.Environment) error) error {
+	byRuntime := make(map[string][]*state.Environment)
+	for _, env := range envs {
+		byRuntime[env.Runtime] = append(byRuntime[env.Runtime], env)
+	}
+	if plat, err := platform.Detect(os.Getenv("ENVCLONE_RUNTIME")); err == nil {
+		if _, ok := byRuntime[plat.Runtime()]; !ok {
+			byRuntime[plat.Runtime()] = nil
+		}
+	}
+	names := make([]string, 0, len(byRuntime))
+	for name := range byRuntime {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		plat, err := platform.Detect(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %d environment(s) using %s: %v\n", len(byRuntime[name]), name, err)
+			continue
+		}
+		rt, err := runtime.New(plat, &exec.Local{DryRun: dryRun})
+		if err != nil {
+			return err
+		}
+		if err := fn(rt, byRuntime[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,14 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/matoval/envclone/internal/container"
-	"github.com/matoval/envclone/internal/exec"
-	"github.com/matoval/envclone/internal/platform"
 	"github.com/matoval/envclone/internal/state"
 	"github.com/spf13/cobra"
 )
 
+var shellService string
+
 var shellCmd = &cobra.Command{
 	Use:   "shell",
 	Short: "Open a shell in the dev container",
@@ -26,22 +27,28 @@ var shellCmd = &cobra.Command{
 			return fmt.Errorf("no environment found (run 'envclone up' first): %w", err)
 		}
 
-		plat, err := platform.Detect()
+		plat, rt, err := detectRuntime(dir, env)
 		if err != nil {
 			return err
 		}
 
-		runner := &exec.Runner{}
 		mgr := &container.Manager{
 			Platform:   plat,
-			Runner:     runner,
+			Runtime:    rt,
 			ProjectDir: dir,
 		}
 
-		return mgr.Shell(ctx, env)
+		reportDrift(ctx, mgr, env)
+
+		workdir := ""
+		if cwd, err := os.Getwd(); err == nil {
+			workdir, _ = container.MapWorkdir(env, cwd)
+		}
+		return mgr.Shell(ctx, env, shellService, workdir)
 	},
 }
 
 func init() {
+	shellCmd.Flags().StringVar(&shellService, "service", "", "run in a service container instead of the dev container")
 	rootCmd.AddCommand(shellCmd)
 }
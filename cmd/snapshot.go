@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/container"
+	"github.com/matoval/envclone/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:         "snapshot [tag]",
+	Annotations: map[string]string{annotationOperation: "true"},
+	Short:       "Commit the running dev container to an image",
+	Long: `Commit the running dev container to a local image, so tools installed
+interactively can be kept. Start from it later with 'envclone up --from-snapshot <tag>'.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		dir, err := getProjectDir()
+		if err != nil {
+			return err
+		}
+
+		env, err := state.Load(dir)
+		if err != nil {
+			return fmt.Errorf("no environment found (run 'envclone up' first): %w", err)
+		}
+
+		plat, rt, err := detectRuntime(dir, env)
+		if err != nil {
+			return err
+		}
+
+		mgr := &container.Manager{
+			Platform:   plat,
+			Runtime:    rt,
+			ProjectDir: dir,
+		}
+		if cfg, err := config.Load(dir); err == nil {
+			mgr.Config = cfg
+		}
+
+		reportDrift(ctx, mgr, env)
+
+		tag := time.Now().Format("20060102-150405")
+		if len(args) > 0 {
+			tag = args[0]
+		}
+
+		snapshots, err := state.LoadSnapshots(dir)
+		if err != nil {
+			return fmt.Errorf("loading snapshots: %w", err)
+		}
+
+		fmt.Printf("Committing dev container as %s...\n", container.SnapshotImage(env.ProjectName, tag))
+		snap, err := mgr.Snapshot(ctx, env, tag)
+		if err != nil {
+			return err
+		}
+
+		kept := snapshots[:0]
+		for _, s := range snapshots {
+			if s.Tag != tag {
+				kept = append(kept, s)
+			}
+		}
+		if err := state.SaveSnapshots(dir, append(kept, snap)); err != nil {
+			return fmt.Errorf("saving snapshot record: %w", err)
+		}
+
+		fmt.Printf("Snapshot %s saved. Start from it with 'envclone up --from-snapshot %s'.\n", tag, tag)
+		return nil
+	},
+}
+
+var snapshotLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List dev container snapshots",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := getProjectDir()
+		if err != nil {
+			return err
+		}
+
+		snapshots, err := state.LoadSnapshots(dir)
+		if err != nil {
+			return fmt.Errorf("loading snapshots: %w", err)
+		}
+
+		current := ""
+		if cfg, err := config.Load(dir); err == nil {
+			current = cfg.Fingerprint()
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TAG\tIMAGE\tCREATED\tCONFIG")
+		for _, snap := range snapshots {
+			drift := "current"
+			if snap.ConfigHash != current {
+				drift = "changed"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", snap.Tag, snap.Image, snap.Created.Format(time.DateTime), drift)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var snapshotRmCmd = &cobra.Command{
+	Use:         "rm <tag...>",
+	Annotations: map[string]string{annotationOperation: "true"},
+	Short:       "Remove dev container snapshots",
+	Args:        cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		dir, err := getProjectDir()
+		if err != nil {
+			return err
+		}
+
+		plat, rt, err := detectRuntime(dir, nil)
+		if err != nil {
+			return err
+		}
+
+		mgr := &container.Manager{
+			Platform:   plat,
+			Runtime:    rt,
+			ProjectDir: dir,
+		}
+
+		snapshots, err := state.LoadSnapshots(dir)
+		if err != nil {
+			return fmt.Errorf("loading snapshots: %w", err)
+		}
+
+		for _, tag := range args {
+			if err := mgr.RemoveSnapshot(ctx, tag); err != nil {
+				return err
+			}
+			kept := snapshots[:0]
+			for _, s := range snapshots {
+				if s.Tag != tag {
+					kept = append(kept, s)
+				}
+			}
+			snapshots = kept
+			if err := state.SaveSnapshots(dir, snapshots); err != nil {
+				return fmt.Errorf("saving snapshot record: %w", err)
+			}
+			fmt.Printf("Removed snapshot %s\n", tag)
+		}
+		return nil
+	},
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotLsCmd, snapshotRmCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
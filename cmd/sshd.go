@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/matoval/envclone/internal/ssh"
+	"github.com/matoval/envclone/internal/sshd"
+	"github.com/pkg/sftp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sshdPort  int
+	sshdDir   string
+	sshdCheck bool
+	sshdSFTP  bool
+)
+
+var sshdCmd = &cobra.Command{
+	Use:   "sshd",
+	Short: "Run envclone's SSH server (inside the dev container)",
+	Long: `Run envclone's built-in SSH server. 'envclone code' copies envclone into
+the dev container and starts it there; it is not meant to be run by hand.
+
+The server keeps its host key, authorized keys, pid file and log in --dir.
+--check exits successfully if a server is already running; --sftp serves
+SFTP on stdin and stdout, which the server runs as the logged-in user for
+the sftp subsystem.`,
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if sshdSFTP {
+			// Relative paths start in the home directory the session
+			// runs in, as with openssh's sftp-server.
+			home, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			server, err := sftp.NewServer(struct {
+				io.Reader
+				io.WriteCloser
+			}{os.Stdin, os.Stdout}, sftp.WithServerWorkingDirectory(home))
+			if err != nil {
+				return err
+			}
+			if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			return nil
+		}
+
+		running := sshd.Running(sshdDir)
+		if sshdCheck {
+			if !running {
+				return exitCodeError{code: 1}
+			}
+			return nil
+		}
+		if running {
+			fmt.Println("envclone sshd is already running.")
+			return nil
+		}
+
+		if err := os.MkdirAll(sshdDir, 0o755); err != nil {
+			return err
+		}
+		logFile, err := os.OpenFile(filepath.Join(sshdDir, sshd.LogFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		defer logFile.Close()
+
+		self, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		server := &sshd.Server{
+			Dir:        sshdDir,
+			Addr:       fmt.Sprintf(":%d", sshdPort),
+			Executable: self,
+			SFTPArgs:   []string{"sshd", "--sftp"},
+			Log:        slog.New(slog.NewTextHandler(logFile, nil)),
+		}
+		return server.ListenAndServe(cmd.Context())
+	},
+}
+
+func init() {
+	sshdCmd.Flags().IntVar(&sshdPort, "port", 2222, "port to listen on")
+	sshdCmd.Flags().StringVar(&sshdDir, "dir", ssh.ServerDir, "directory with the host key, authorized keys, pid file and log")
+	sshdCmd.Flags().BoolVar(&sshdCheck, "check", false, "exit successfully if a server is already running")
+	sshdCmd.Flags().BoolVar(&sshdSFTP, "sftp", false, "serve SFTP on stdin and stdout")
+	rootCmd.AddCommand(sshdCmd)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/matoval/envclone/internal/container"
+	"github.com/spf13/cobra"
+)
+
+// statsInterval is how often stats refreshes, as docker stats does.
+const statsInterval = 2 * time.Second
+
+var statsNoStream bool
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show live resource usage of the environment's containers",
+	Long: `Show the CPU, memory, network I/O, block I/O and process count of the dev
+container and each service, with a total row for the whole environment.
+The table refreshes every two seconds until interrupted; --no-stream prints
+it once. Containers that share the network namespace report the same
+network I/O, so the total counts it once.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		mgr, env, err := newControlManager(ctx)
+		if err != nil {
+			return err
+		}
+
+		clear := isTerminal(os.Stdout) && !statsNoStream
+		ticker := time.NewTicker(statsInterval)
+		defer ticker.Stop()
+		for {
+			stats, err := mgr.Stats(ctx, env)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+			if clear {
+				fmt.Print("\x1b[H\x1b[2J")
+			}
+			printStats(os.Stdout, stats)
+			if statsNoStream {
+				return nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+			if !clear {
+				fmt.Println()
+			}
+		}
+	},
+}
+
+// printStats writes the stats table followed by the total row.
+func printStats(out io.Writer, stats []container.ContainerStats) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCPU %\tMEM USAGE / LIMIT\tNET I/O\tBLOCK I/O\tPIDS")
+	var total container.ContainerStats
+	for _, s := range stats {
+		if !s.Running {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\n", s.Target)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", s.Target, statsColumns(s))
+		total.CPUPercent += s.CPUPercent
+		total.MemoryUsage += s.MemoryUsage
+		total.MemoryLimit = max(total.MemoryLimit, s.MemoryLimit)
+		total.NetRx = max(total.NetRx, s.NetRx)
+		total.NetTx = max(total.NetTx, s.NetTx)
+		total.BlockRead += s.BlockRead
+		total.BlockWrite += s.BlockWrite
+		total.PIDs += s.PIDs
+	}
+	fmt.Fprintf(w, "TOTAL\t%s\n", statsColumns(total))
+	w.Flush()
+}
+
+func statsColumns(s container.ContainerStats) string {
+	return fmt.Sprintf("%.1f%%\t%s / %s\t%s / %s\t%s / %s\t%d",
+		s.CPUPercent,
+		formatSize(int64(s.MemoryUsage)), formatSize(int64(s.MemoryLimit)),
+		formatSize(int64(s.NetRx)), formatSize(int64(s.NetTx)),
+		formatSize(int64(s.BlockRead)), formatSize(int64(s.BlockWrite)),
+		s.PIDs)
+}
+
+func init() {
+	statsCmd.Flags().BoolVar(&statsNoStream, "no-stream", false, "print the usage once instead of refreshing it")
+	rootCmd.AddCommand(statsCmd)
+}
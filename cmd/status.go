@@ -2,12 +2,13 @@ package cmd
 
 import (
 	"fmt"
-	"text/tabwriter"
 	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/matoval/envclone/internal/container"
-	"github.com/matoval/envclone/internal/exec"
-	"github.com/matoval/envclone/internal/platform"
+	"github.com/matoval/envclone/internal/runtime"
 	"github.com/matoval/envclone/internal/state"
 	"github.com/spf13/cobra"
 )
@@ -29,15 +30,19 @@ var statusCmd = &cobra.Command{
 			return nil
 		}
 
-		plat, err := platform.Detect()
+		if env.Failed() {
+			fmt.Println("Environment is in a failed state (last 'envclone up' did not complete):")
+			fmt.Printf("  %s\n\n", env.Error)
+		}
+
+		plat, rt, err := detectRuntime(dir, env)
 		if err != nil {
 			return err
 		}
 
-		runner := &exec.Runner{}
 		mgr := &container.Manager{
 			Platform:   plat,
-			Runner:     runner,
+			Runtime:    rt,
 			ProjectDir: dir,
 		}
 
@@ -47,15 +52,48 @@ var statusCmd = &cobra.Command{
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
-		fmt.Fprintln(w, "NAME\tROLE\tSTATUS")
+		fmt.Fprintln(w, "NAME\tROLE\tSTATE\tIMAGE\tCREATED\tRESTARTS\tPORTS")
 		for _, info := range infos {
-			fmt.Fprintf(w, "%s\t%s\t%s\n", info.Name, info.Role, info.Status)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+				info.Name, info.Role, containerState(info.Container), info.Image,
+				info.Created.Format(time.DateTime), info.RestartCount, formatPorts(info.Ports))
 		}
 		w.Flush()
+
+		drift, err := mgr.Drift(ctx, env)
+		if err != nil {
+			return err
+		}
+		if len(drift) > 0 {
+			fmt.Println("\nDrift from the recorded environment:")
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			for _, d := range drift {
+				fmt.Fprintf(w, "  %s\t%s\t%s\n", d.Name, d.Kind, d.Detail)
+			}
+			w.Flush()
+			fmt.Println("Run 'envclone repair' to fix it.")
+		}
 		return nil
 	},
 }
 
+// containerState describes a container's state, with the exit code for
+// containers that have stopped.
+func containerState(c runtime.Container) string {
+	if c.State == "exited" {
+		return fmt.Sprintf("exited (%d)", c.ExitCode)
+	}
+	return c.State
+}
+
+func formatPorts(ports []runtime.Port) string {
+	var specs []string
+	for _, p := range ports {
+		specs = append(specs, p.String())
+	}
+	return strings.Join(specs, ", ")
+}
+
 func init() {
 	rootCmd.AddCommand(statusCmd)
 }
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top [service]",
+	Short: "List the processes running in a container",
+	Long: `List the processes running in the dev container, or in the named
+service.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		mgr, env, err := newControlManager(ctx)
+		if err != nil {
+			return err
+		}
+
+		target := ""
+		if len(args) == 1 {
+			target = args[0]
+		}
+		procs, err := mgr.Top(ctx, env, target)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(procs.Titles, "\t"))
+		for _, row := range procs.Rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(topCmd)
+}
@@ -5,15 +5,23 @@ import (
 
 	"github.com/matoval/envclone/internal/config"
 	"github.com/matoval/envclone/internal/container"
-	"github.com/matoval/envclone/internal/exec"
-	"github.com/matoval/envclone/internal/platform"
 	"github.com/matoval/envclone/internal/state"
 	"github.com/spf13/cobra"
 )
 
+var (
+	keepOnFailure bool
+	fromSnapshot  string
+	noSnapshot    bool
+)
+
 var upCmd = &cobra.Command{
 	Use:   "up",
 	Short: "Start the dev environment",
+	Long: `Start the dev environment, or bring a running one in line with
+devcontainer.json. Containers that still match their configuration are
+kept; see 'envclone plan' for what up would change.`,
+	Annotations: map[string]string{annotationDryRun: "true", annotationOperation: "true"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
@@ -27,7 +35,38 @@ var upCmd = &cobra.Command{
 			return err
 		}
 
-		plat, err := platform.Detect()
+		if fromSnapshot != "" && noSnapshot {
+			return fmt.Errorf("--from-snapshot and --no-snapshot cannot be used together")
+		}
+
+		// State from an unreadable or newer schema is not carried over.
+		var prev *state.Environment
+		if env, err := state.Load(dir); err == nil {
+			prev = env
+		}
+
+		// The dev container keeps starting from the snapshot it was
+		// started from until --no-snapshot.
+		snapshot := fromSnapshot
+		if snapshot == "" && !noSnapshot && prev != nil {
+			snapshot = prev.Snapshot
+		}
+
+		if fromSnapshot != "" {
+			snapshots, err := state.LoadSnapshots(dir)
+			if err != nil {
+				return fmt.Errorf("loading snapshots: %w", err)
+			}
+			snap, ok := state.FindSnapshot(snapshots, fromSnapshot)
+			if !ok {
+				return fmt.Errorf("no snapshot tagged %q (see 'envclone snapshot ls')", fromSnapshot)
+			}
+			if snap.ConfigHash != cfg.Fingerprint() {
+				fmt.Printf("Warning: devcontainer.json has changed since snapshot %s was taken\n", snap.Tag)
+			}
+		}
+
+		plat, rt, err := detectRuntime(dir, nil)
 		if err != nil {
 			return err
 		}
@@ -36,16 +75,38 @@ var upCmd = &cobra.Command{
 			return fmt.Errorf("runtime not ready: %w", err)
 		}
 
-		runner := &exec.Runner{}
 		mgr := &container.Manager{
-			Platform:   plat,
-			Runner:     runner,
-			Config:     cfg,
-			ProjectDir: dir,
+			Platform:      plat,
+			Runtime:       rt,
+			Config:        cfg,
+			ProjectDir:    dir,
+			KeepOnFailure: keepOnFailure,
+			FromSnapshot:  snapshot,
+			Previous:      prev,
 		}
 
 		env, err := mgr.Up(ctx)
+		if dryRun {
+			if err == nil {
+				fmt.Println("Dry run: no changes were made.")
+			}
+			return err
+		}
 		if err != nil {
+			if env != nil {
+				if saveErr := state.Save(dir, env); saveErr != nil {
+					return fmt.Errorf("%w (saving failed state: %v)", err, saveErr)
+				}
+				if keepOnFailure {
+					fmt.Println("Environment left in failed state for debugging.")
+					fmt.Println("Run 'envclone status' to inspect it, 'envclone down' to clean up.")
+				} else {
+					// Up rolled back, but containers it removed to
+					// recreate them are gone.
+					fmt.Println("Environment recorded as failed without the containers up removed.")
+					fmt.Println("Run 'envclone repair' to recreate them, 'envclone down' to clean up.")
+				}
+			}
 			return err
 		}
 
@@ -63,5 +124,8 @@ var upCmd = &cobra.Command{
 }
 
 func init() {
+	upCmd.Flags().BoolVar(&keepOnFailure, "keep-on-failure", false, "leave partially created containers in place if up fails")
+	upCmd.Flags().StringVar(&fromSnapshot, "from-snapshot", "", "start the dev container from a snapshot taken with 'envclone snapshot'")
+	upCmd.Flags().BoolVar(&noSnapshot, "no-snapshot", false, "start the dev container from devcontainer.json again instead of the snapshot it was started from")
 	rootCmd.AddCommand(upCmd)
 }
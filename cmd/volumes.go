@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/matoval/envclone/internal/container"
+	"github.com/spf13/cobra"
+)
+
+var volumesCmd = &cobra.Command{
+	Use:   "volumes",
+	Short: "Manage the project's named volumes",
+}
+
+var volumesLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the project's named volumes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		mgr, err := newVolumeManager()
+		if err != nil {
+			return err
+		}
+
+		infos, err := mgr.Volumes(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVOLUME\tDRIVER")
+		for _, info := range infos {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", info.Name, info.FullName, info.Driver)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var volumesRmCmd = &cobra.Command{
+	Use:         "rm <name...>",
+	Annotations: map[string]string{annotationOperation: "true"},
+	Short:       "Remove named volumes (the environment must be down)",
+	Args:        cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		mgr, err := newVolumeManager()
+		if err != nil {
+			return err
+		}
+
+		if err := mgr.RemoveVolumes(ctx, args...); err != nil {
+			return err
+		}
+		for _, name := range args {
+			fmt.Printf("Removed volume %s\n", name)
+		}
+		return nil
+	},
+}
+
+var volumesInspectCmd = &cobra.Command{
+	Use:   "inspect <name>",
+	Short: "Show details of a named volume",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		mgr, err := newVolumeManager()
+		if err != nil {
+			return err
+		}
+
+		vol, err := mgr.InspectVolume(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		out, err := json.MarshalIndent(vol, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+// newVolumeManager returns a manager for volume commands. Volumes outlive
+// the environment, so no state is required.
+func newVolumeManager() (*container.Manager, error) {
+	dir, err := getProjectDir()
+	if err != nil {
+		return nil, err
+	}
+
+	plat, rt, err := detectRuntime(dir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &container.Manager{
+		Platform:   plat,
+		Runtime:    rt,
+		ProjectDir: dir,
+	}, nil
+}
+
+func init() {
+	volumesCmd.AddCommand(volumesLsCmd, volumesRmCmd, volumesInspectCmd)
+	rootCmd.AddCommand(volumesCmd)
+}
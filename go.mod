@@ -2,9 +2,17 @@ module github.com/matoval/envclone
 
 go 1.24.9
 
-require github.com/spf13/cobra v1.10.2
+require (
+	github.com/creack/pty v1.1.24
+	github.com/pkg/sftp v1.13.10
+	github.com/spf13/cobra v1.10.2
+	golang.org/x/crypto v0.43.0
+	golang.org/x/term v0.36.0
+)
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
+	golang.org/x/sys v0.37.0 // indirect
 )
@@ -1,10 +1,12 @@
 package config
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 )
 
 type BuildConfig struct {
@@ -20,21 +22,62 @@ type Customizations struct {
 	VSCode *VSCodeCustomizations `json:"vscode,omitempty"`
 }
 
+// userEnvProbe values, as defined by the devcontainer spec.
+const (
+	UserEnvProbeNone                  = "none"
+	UserEnvProbeLoginShell            = "loginShell"
+	UserEnvProbeLoginInteractiveShell = "loginInteractiveShell"
+	UserEnvProbeInteractiveShell      = "interactiveShell"
+)
+
 type DevContainer struct {
-	Name              string            `json:"name"`
-	Image             string            `json:"image,omitempty"`
-	Build             *BuildConfig      `json:"build,omitempty"`
-	WorkspaceFolder   string            `json:"workspaceFolder,omitempty"`
-	WorkspaceMount    string            `json:"workspaceMount,omitempty"`
-	ForwardPorts      []int             `json:"forwardPorts,omitempty"`
-	PostCreateCommand string            `json:"postCreateCommand,omitempty"`
-	PostStartCommand  string            `json:"postStartCommand,omitempty"`
-	RemoteUser        string            `json:"remoteUser,omitempty"`
-	Mounts            []string          `json:"mounts,omitempty"`
-	Features          map[string]any    `json:"features,omitempty"`
-	RunArgs           []string          `json:"runArgs,omitempty"`
-	Services          []ServiceConfig   `json:"services,omitempty"`
-	Customizations    *Customizations   `json:"customizations,omitempty"`
+	Name              string          `json:"name"`
+	Image             string          `json:"image,omitempty"`
+	Build             *BuildConfig    `json:"build,omitempty"`
+	WorkspaceFolder   string          `json:"workspaceFolder,omitempty"`
+	WorkspaceMount    string          `json:"workspaceMount,omitempty"`
+	ForwardPorts      []int           `json:"forwardPorts,omitempty"`
+	PostCreateCommand string          `json:"postCreateCommand,omitempty"`
+	PostStartCommand  string          `json:"postStartCommand,omitempty"`
+	RemoteUser        string          `json:"remoteUser,omitempty"`
+	Shell             string          `json:"shell,omitempty"`
+	UserEnvProbe      string          `json:"userEnvProbe,omitempty"`
+	Mounts            []string        `json:"mounts,omitempty"`
+	Features          map[string]any  `json:"features,omitempty"`
+	RunArgs           []string        `json:"runArgs,omitempty"`
+	Services          []ServiceConfig `json:"services,omitempty"`
+	Customizations    *Customizations `json:"customizations,omitempty"`
+
+	// HostRequirements are the resources the environment needs. envclone
+	// warns when the host has less, and caps the dev container at cpus
+	// and memory.
+	HostRequirements *HostRequirements `json:"hostRequirements,omitempty"`
+
+	// Runtime selects the container runtime: "nerdctl", "docker" or
+	// "podman". It is an envclone extension; empty means auto-detect.
+	Runtime string `json:"runtime,omitempty"`
+
+	// RedactPatterns are extra environment variable name patterns whose
+	// values envclone masks in logs, errors and output, on top of
+	// PASSWORD, TOKEN, SECRET and KEY. It is an envclone extension.
+	RedactPatterns []string `json:"redactPatterns,omitempty"`
+
+	// Secrets are read on the host at up and mounted as files under
+	// /run/secrets, keyed by file name. It is an envclone extension.
+	Secrets map[string]SecretConfig `json:"secrets,omitempty"`
+}
+
+// SecretConfig says where a secret comes from and which containers get
+// it. Exactly one of Env, File and Command is set.
+type SecretConfig struct {
+	Env     string `json:"env,omitempty"`     // host environment variable
+	File    string `json:"file,omitempty"`    // host file; ~ and ${localEnv:VAR} are expanded
+	Command string `json:"command,omitempty"` // host command printing the secret, e.g. "pass show x"
+
+	// Services also get the secret; the dev container always does.
+	Services []string `json:"services,omitempty"`
+	// EnvVar also exposes the secret as this environment variable.
+	EnvVar string `json:"envVar,omitempty"`
 }
 
 type ServiceConfig struct {
@@ -43,11 +86,19 @@ type ServiceConfig struct {
 	Ports   []string `json:"ports,omitempty"`
 	Env     []string `json:"env,omitempty"`
 	Volumes []string `json:"volumes,omitempty"`
+
+	// Resources limits the service container. It is an envclone
+	// extension.
+	Resources *Resources `json:"resources,omitempty"`
+}
+
+// Path returns the path of a project's devcontainer.json.
+func Path(projectDir string) string {
+	return filepath.Join(projectDir, ".devcontainer", "devcontainer.json")
 }
 
 func Load(projectDir string) (*DevContainer, error) {
-	configPath := filepath.Join(projectDir, ".devcontainer", "devcontainer.json")
-	data, err := os.ReadFile(configPath)
+	data, err := os.ReadFile(Path(projectDir))
 	if err != nil {
 		return nil, fmt.Errorf("reading devcontainer.json: %w", err)
 	}
@@ -63,9 +114,81 @@ func Load(projectDir string) (*DevContainer, error) {
 	if cfg.Build != nil && cfg.Build.Dockerfile == "" {
 		return nil, fmt.Errorf("devcontainer.json: \"build.dockerfile\" cannot be empty")
 	}
+	switch cfg.UserEnvProbe {
+	case "", UserEnvProbeNone, UserEnvProbeLoginShell, UserEnvProbeLoginInteractiveShell, UserEnvProbeInteractiveShell:
+	default:
+		return nil, fmt.Errorf("devcontainer.json: unknown \"userEnvProbe\" %q", cfg.UserEnvProbe)
+	}
+	if err := validateSecrets(&cfg); err != nil {
+		return nil, err
+	}
+	if err := validateResources(&cfg); err != nil {
+		return nil, err
+	}
 	if cfg.Name == "" {
 		cfg.Name = filepath.Base(projectDir)
 	}
 
 	return &cfg, nil
 }
+
+var (
+	secretNameRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+	envVarRe     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+func validateSecrets(cfg *DevContainer) error {
+	services := make(map[string]bool)
+	for _, svc := range cfg.Services {
+		services[svc.Name] = true
+	}
+	for name, secret := range cfg.Secrets {
+		if !secretNameRe.MatchString(name) {
+			return fmt.Errorf("devcontainer.json: secret %q: name must be a plain file name", name)
+		}
+		sources := 0
+		for _, s := range []string{secret.Env, secret.File, secret.Command} {
+			if s != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("devcontainer.json: secret %q: set exactly one of \"env\", \"file\" and \"command\"", name)
+		}
+		for _, svc := range secret.Services {
+			if !services[svc] {
+				return fmt.Errorf("devcontainer.json: secret %q: unknown service %q", name, svc)
+			}
+		}
+		if secret.EnvVar != "" && !envVarRe.MatchString(secret.EnvVar) {
+			return fmt.Errorf("devcontainer.json: secret %q: invalid \"envVar\" %q", name, secret.EnvVar)
+		}
+	}
+	return nil
+}
+
+// FeatureDigests returns a short hash of each feature's options, by
+// feature ID, so a change to a feature's options can be told apart from a
+// change elsewhere in the config.
+func (c *DevContainer) FeatureDigests() map[string]string {
+	if len(c.Features) == 0 {
+		return nil
+	}
+	digests := make(map[string]string, len(c.Features))
+	for id, opts := range c.Features {
+		data, _ := json.Marshal(opts)
+		digests[id] = fmt.Sprintf("%x", sha256.Sum256(append([]byte(id+"\n"), data...)))[:12]
+	}
+	return digests
+}
+
+// Fingerprint returns a short hash of the effective configuration. It
+// changes whenever a setting that affects the environment changes, and is
+// independent of formatting and comments in devcontainer.json.
+func (c *DevContainer) Fingerprint() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:12]
+}
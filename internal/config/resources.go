@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HostRequirements is the devcontainer.json "hostRequirements" property.
+// CPUs and Memory become limits on the dev container. Storage is only
+// checked against the host's free space: the runtimes can limit a
+// container's disk only on some storage drivers.
+type HostRequirements struct {
+	CPUs    int    `json:"cpus,omitempty"`
+	Memory  string `json:"memory,omitempty"`  // e.g. "8gb"
+	Storage string `json:"storage,omitempty"` // e.g. "32gb"
+}
+
+// Resources limits what a service container may use.
+type Resources struct {
+	CPUs   float64 `json:"cpus,omitempty"`   // e.g. 0.5
+	Memory string  `json:"memory,omitempty"` // e.g. "512mb"
+	PIDs   int     `json:"pids,omitempty"`
+}
+
+// memoryUnits are the size suffixes devcontainer.json uses. As in the
+// spec and the runtimes' --memory flag, they are powers of 1024.
+var memoryUnits = map[string]int64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kb": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40,
+}
+
+// ParseSize parses a size such as "8gb", "512m" or "1.5gb" into bytes.
+func ParseSize(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i == -1 {
+		i = len(s)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	unit, ok := memoryUnits[s[i:]]
+	if err != nil || !ok || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 512mb or 8gb)", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+func validateResources(cfg *DevContainer) error {
+	if req := cfg.HostRequirements; req != nil {
+		if req.CPUs < 0 {
+			return fmt.Errorf("devcontainer.json: \"hostRequirements.cpus\" must be positive")
+		}
+		for _, f := range []struct{ field, size string }{{"memory", req.Memory}, {"storage", req.Storage}} {
+			if f.size == "" {
+				continue
+			}
+			if _, err := ParseSize(f.size); err != nil {
+				return fmt.Errorf("devcontainer.json: \"hostRequirements.%s\": %w", f.field, err)
+			}
+		}
+	}
+	for _, svc := range cfg.Services {
+		res := svc.Resources
+		if res == nil {
+			continue
+		}
+		if res.CPUs < 0 || res.PIDs < 0 {
+			return fmt.Errorf("devcontainer.json: service %q: \"resources\" cpus and pids must be positive", svc.Name)
+		}
+		if res.Memory != "" {
+			if _, err := ParseSize(res.Memory); err != nil {
+				return fmt.Errorf("devcontainer.json: service %q: \"resources.memory\": %w", svc.Name, err)
+			}
+		}
+	}
+	return nil
+}
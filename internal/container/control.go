@@ -0,0 +1,164 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/matoval/envclone/internal/state"
+)
+
+// DevTarget names the dev container wherever commands accept service names.
+const DevTarget = "dev"
+
+// resolveTargets checks that each target names a container of the
+// environment. With no targets it returns all services followed by the dev
+// container, leaving out the network namespace that everything else joins.
+func (m *Manager) resolveTargets(ctx context.Context, env *state.Environment, targets []string) ([]string, error) {
+	infos, err := m.Status(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+
+	prefix := fmt.Sprintf("envclone-%s-", env.ProjectName)
+	var available []string
+	hasDev := false
+	for _, info := range infos {
+		switch info.Role {
+		case "service":
+			available = append(available, strings.TrimPrefix(info.Name, prefix))
+		case "dev":
+			hasDev = true
+		}
+	}
+	if hasDev {
+		available = append(available, DevTarget)
+	}
+
+	if len(targets) == 0 {
+		return available, nil
+	}
+
+	for _, target := range targets {
+		found := false
+		for _, name := range available {
+			if name == target {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no container for %q (available: %s)", target, strings.Join(available, ", "))
+		}
+	}
+	return targets, nil
+}
+
+// Stop stops the given containers, or all of them. They are marked as
+// stopped in env, so they are not reported as drift.
+func (m *Manager) Stop(ctx context.Context, env *state.Environment, targets []string) error {
+	return m.control(ctx, env, "stop", targets)
+}
+
+// Start starts the given stopped containers, or all of them. Starting the
+// dev container runs postStartCommand.
+func (m *Manager) Start(ctx context.Context, env *state.Environment, targets []string) error {
+	return m.control(ctx, env, "start", targets)
+}
+
+// Restart restarts the given containers, or all of them. Restarting the dev
+// container runs postStartCommand.
+func (m *Manager) Restart(ctx context.Context, env *state.Environment, targets []string) error {
+	return m.control(ctx, env, "restart", targets)
+}
+
+func (m *Manager) control(ctx context.Context, env *state.Environment, action string, targets []string) error {
+	targets, err := m.resolveTargets(ctx, env, targets)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		name := fmt.Sprintf("envclone-%s-%s", env.ProjectName, target)
+		var err error
+		switch action {
+		case "stop":
+			err = m.Runtime.Stop(ctx, name)
+		case "start":
+			err = m.Runtime.Start(ctx, name)
+		default:
+			err = m.Runtime.Restart(ctx, name)
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", action, target, err)
+		}
+		env.SetStopped(name, action == "stop")
+		if action == "stop" {
+			continue
+		}
+		// The secrets tmpfs starts out empty.
+		if m.Config != nil {
+			if err := m.writeSecrets(ctx, name, m.secretNames(target)); err != nil {
+				return err
+			}
+		}
+		if target == DevTarget {
+			m.runPostStart(ctx, env)
+		}
+	}
+	return nil
+}
+
+// Recreate removes a container and creates it again from the current
+// config, picking up changed images, env vars or mounts. env is updated
+// with the new container ID.
+func (m *Manager) Recreate(ctx context.Context, env *state.Environment, target string) error {
+	if _, err := m.resolveTargets(ctx, env, []string{target}); err != nil {
+		return err
+	}
+	netNSContainer := fmt.Sprintf("envclone-%s-netns", env.ProjectName)
+
+	if target == DevTarget {
+		if err := m.Runtime.Remove(ctx, fmt.Sprintf("envclone-%s-dev", env.ProjectName)); err != nil {
+			return fmt.Errorf("removing dev container: %w", err)
+		}
+		id, err := m.createDevContainer(ctx, env, netNSContainer)
+		if err != nil {
+			return fmt.Errorf("creating dev container: %w", err)
+		}
+		env.DevContainerID = id
+		m.probeUserEnv(ctx, env)
+
+		lifecycleLog := m.openLifecycleLog(false)
+		defer lifecycleLog.Close()
+		m.runLifecycleCommand(ctx, env, "postCreateCommand", m.Config.PostCreateCommand, lifecycleLog)
+		m.runLifecycleCommand(ctx, env, "postStartCommand", m.Config.PostStartCommand, lifecycleLog)
+		return nil
+	}
+
+	svc, err := m.service(target)
+	if err != nil {
+		return err
+	}
+	oldID, err := m.removeService(ctx, env.ProjectName, target)
+	if err != nil {
+		return err
+	}
+	newID, err := m.createService(ctx, env, netNSContainer, svc)
+	if err != nil {
+		return fmt.Errorf("creating service %s: %w", target, err)
+	}
+	replaceServiceID(env, oldID, newID)
+	return nil
+}
+
+// runPostStart runs postStartCommand after the dev container was started
+// outside of up. It needs the config and is skipped without one.
+func (m *Manager) runPostStart(ctx context.Context, env *state.Environment) {
+	if m.Config == nil || m.Config.PostStartCommand == "" {
+		return
+	}
+	lifecycleLog := m.openLifecycleLog(true)
+	defer lifecycleLog.Close()
+	m.runLifecycleCommand(ctx, env, "postStartCommand", m.Config.PostStartCommand, lifecycleLog)
+}
@@ -0,0 +1,312 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+// helperImage runs the tar commands that copy data in and out of volumes.
+const helperImage = "docker.io/library/busybox:1.36"
+
+// DataSnapshot describes a saved copy of a service's named volumes.
+type DataSnapshot struct {
+	Service string
+	Name    string
+	Path    string
+	Size    int64
+	Created time.Time
+}
+
+func (m *Manager) service(name string) (config.ServiceConfig, error) {
+	for _, svc := range m.Config.Services {
+		if svc.Name == name {
+			return svc, nil
+		}
+	}
+	return config.ServiceConfig{}, fmt.Errorf("no service named %q in devcontainer.json", name)
+}
+
+// serviceVolumes returns the names of the named volumes a service mounts.
+func serviceVolumes(svc config.ServiceConfig) []string {
+	var names []string
+	for _, spec := range svc.Volumes {
+		if name, ok := namedVolume(spec); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkSnapshotName rejects snapshot names that are not a single path
+// element, so a snapshot cannot be written or read outside its service's
+// directory.
+func checkSnapshotName(name string) error {
+	if name == "" || name == "." || strings.Contains(name, "..") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return nil
+}
+
+func (m *Manager) dataVolumes(service string) ([]string, error) {
+	svc, err := m.service(service)
+	if err != nil {
+		return nil, err
+	}
+	volumes := serviceVolumes(svc)
+	if len(volumes) == 0 {
+		return nil, fmt.Errorf("service %s has no named volumes", service)
+	}
+	return volumes, nil
+}
+
+// SnapshotData stops the service, archives each of its named volumes into
+// dir/<service>/<name>/ and starts the service again.
+func (m *Manager) SnapshotData(ctx context.Context, env *state.Environment, service, name, dir string) error {
+	if err := checkSnapshotName(name); err != nil {
+		return err
+	}
+	volumes, err := m.dataVolumes(service)
+	if err != nil {
+		return err
+	}
+
+	dest := filepath.Join(dir, service, name)
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("snapshot %s of %s already exists", name, service)
+	}
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return fmt.Errorf("creating snapshot directory: %w", err)
+	}
+
+	err = m.withServiceStopped(ctx, env, service, func() error {
+		for _, vol := range volumes {
+			script := fmt.Sprintf("tar czf /backup/%s.tar.gz -C /data .", vol)
+			if err := m.runHelper(ctx, env.ProjectName, vol, dest, script); err != nil {
+				return fmt.Errorf("archiving volume %s: %w", vol, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		os.RemoveAll(dest)
+		return err
+	}
+	return nil
+}
+
+// RestoreData stops the service, replaces the contents of its named volumes
+// with a snapshot taken by SnapshotData and starts the service again.
+func (m *Manager) RestoreData(ctx context.Context, env *state.Environment, service, name, dir string) error {
+	if err := checkSnapshotName(name); err != nil {
+		return err
+	}
+	volumes, err := m.dataVolumes(service)
+	if err != nil {
+		return err
+	}
+
+	src := filepath.Join(dir, service, name)
+	for _, vol := range volumes {
+		if _, err := os.Stat(filepath.Join(src, vol+".tar.gz")); err != nil {
+			return fmt.Errorf("snapshot %s of %s has no archive for volume %s", name, service, vol)
+		}
+	}
+
+	return m.withServiceStopped(ctx, env, service, func() error {
+		for _, vol := range volumes {
+			script := fmt.Sprintf("find /data -mindepth 1 -delete && tar xzf /backup/%s.tar.gz -C /data", vol)
+			if err := m.runHelper(ctx, env.ProjectName, vol, src, script); err != nil {
+				return fmt.Errorf("restoring volume %s: %w", vol, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ResetData removes the service container and its named volumes and creates
+// the service again, so the image initialises fresh data. The new container
+// ID replaces the old one in env.ServiceIDs. A volume that cannot be removed,
+// e.g. because another container mounts it, still fails the reset, but the
+// service is created again first, with the data that is left.
+func (m *Manager) ResetData(ctx context.Context, env *state.Environment, service string) error {
+	svc, err := m.service(service)
+	if err != nil {
+		return err
+	}
+
+	oldID, err := m.removeService(ctx, env.ProjectName, service)
+	if err != nil {
+		return err
+	}
+
+	var rmErr error
+	for _, vol := range serviceVolumes(svc) {
+		if err := m.Runtime.RemoveVolumes(ctx, VolumeName(env.ProjectName, vol)); err != nil {
+			rmErr = fmt.Errorf("removing volume %s: %w", vol, err)
+			break
+		}
+	}
+
+	netNSContainer := fmt.Sprintf("envclone-%s-netns", env.ProjectName)
+	newID, err := m.createService(context.WithoutCancel(ctx), env, netNSContainer, svc)
+	if err != nil {
+		// The old container is gone either way.
+		removeServiceID(env, oldID)
+		if rmErr != nil {
+			err = fmt.Errorf("%w; %w", rmErr, err)
+		}
+		return fmt.Errorf("creating service %s: %w\nRun 'envclone repair' to create it again.", service, err)
+	}
+	replaceServiceID(env, oldID, newID)
+	if rmErr != nil {
+		return fmt.Errorf("%w\n%s was started again without resetting its data.", rmErr, service)
+	}
+	return nil
+}
+
+// DataSnapshots lists the snapshots in dir, optionally only those of one
+// service, sorted by service and creation time.
+func DataSnapshots(dir, service string) ([]DataSnapshot, error) {
+	services := []string{service}
+	if service == "" {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		services = services[:0]
+		for _, e := range entries {
+			if e.IsDir() {
+				services = append(services, e.Name())
+			}
+		}
+	}
+
+	var snapshots []DataSnapshot
+	for _, svc := range services {
+		entries, err := os.ReadDir(filepath.Join(dir, svc))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			snap := DataSnapshot{Service: svc, Name: e.Name(), Path: filepath.Join(dir, svc, e.Name())}
+			files, err := os.ReadDir(snap.Path)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range files {
+				fi, err := f.Info()
+				if err != nil {
+					continue
+				}
+				snap.Size += fi.Size()
+				if fi.ModTime().After(snap.Created) {
+					snap.Created = fi.ModTime()
+				}
+			}
+			snapshots = append(snapshots, snap)
+		}
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].Service != snapshots[j].Service {
+			return snapshots[i].Service < snapshots[j].Service
+		}
+		return snapshots[i].Created.Before(snapshots[j].Created)
+	})
+	return snapshots, nil
+}
+
+// withServiceStopped stops a service container for the duration of fn so
+// its volumes are consistent on disk, and starts it again afterwards with
+// its secrets rewritten, as the secrets tmpfs starts out empty.
+func (m *Manager) withServiceStopped(ctx context.Context, env *state.Environment, service string, fn func() error) error {
+	containerName := fmt.Sprintf("envclone-%s-%s", env.ProjectName, service)
+
+	if err := m.Runtime.Stop(ctx, containerName); err != nil {
+		return fmt.Errorf("stopping %s: %w", service, err)
+	}
+
+	fnErr := fn()
+
+	if err := m.Runtime.Start(context.WithoutCancel(ctx), containerName); err != nil {
+		if fnErr != nil {
+			return fnErr
+		}
+		return fmt.Errorf("starting %s: %w", service, err)
+	}
+	if err := m.writeSecrets(context.WithoutCancel(ctx), containerName, m.secretNames(service)); err != nil && fnErr == nil {
+		return err
+	}
+	return fnErr
+}
+
+// runHelper runs script in a throwaway container with the project volume
+// mounted at /data and hostDir mounted at /backup.
+func (m *Manager) runHelper(ctx context.Context, projectName, volume, hostDir, script string) error {
+	_, err := m.Runtime.Run(ctx, runtime.RunOptions{
+		Image:   helperImage,
+		Command: []string{"sh", "-c", script},
+		Remove:  true,
+		Volumes: []string{
+			VolumeName(projectName, volume) + ":/data",
+			hostDir + ":/backup",
+		},
+	})
+	return err
+}
+
+// removeService force-removes a service container and returns its ID.
+func (m *Manager) removeService(ctx context.Context, projectName, service string) (string, error) {
+	containerName := fmt.Sprintf("envclone-%s-%s", projectName, service)
+
+	c, err := m.Runtime.Inspect(ctx, containerName)
+	if err != nil {
+		return "", fmt.Errorf("finding container for %s: %w", service, err)
+	}
+
+	if err := m.Runtime.Remove(ctx, containerName); err != nil {
+		return "", fmt.Errorf("removing %s: %w", service, err)
+	}
+	return c.ID, nil
+}
+
+// replaceServiceID swaps a recreated service's container ID in env,
+// appending it if the old ID was not recorded.
+func replaceServiceID(env *state.Environment, oldID, newID string) {
+	for i, id := range env.ServiceIDs {
+		if id == oldID {
+			env.ServiceIDs[i] = newID
+			return
+		}
+	}
+	env.ServiceIDs = append(env.ServiceIDs, newID)
+}
+
+// removeServiceID drops a removed service's container ID from env.
+func removeServiceID(env *state.Environment, id string) {
+	kept := env.ServiceIDs[:0]
+	for _, other := range env.ServiceIDs {
+		if other != id {
+			kept = append(kept, other)
+		}
+	}
+	env.ServiceIDs = kept
+}
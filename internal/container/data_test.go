@@ -0,0 +1,87 @@
+package container
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/state"
+)
+
+func TestCheckSnapshotName(t *testing.T) {
+	for _, name := range []string{"20240102-150405", "before-migration", "v1.2"} {
+		if err := checkSnapshotName(name); err != nil {
+			t.Errorf("checkSnapshotName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "../x", "a/b", `a\b`, "x..y"} {
+		if err := checkSnapshotName(name); err == nil {
+			t.Errorf("checkSnapshotName(%q) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestDataRewritesSecrets(t *testing.T) {
+	v := variants[0]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "data.replay"))
+	mgr.Config.Secrets = map[string]config.SecretConfig{
+		"db_password": {Command: "echo pw", Services: []string{"postgres"}},
+		"api_key":     {Command: "echo key"},
+	}
+	env := &state.Environment{ProjectName: "myapp"}
+	dir := t.TempDir()
+
+	if err := mgr.SnapshotData(context.Background(), env, "postgres", "seeded", dir); err != nil {
+		t.Fatalf("SnapshotData: %v", err)
+	}
+	// The helper container writes the archive; the recorder does not.
+	if err := os.WriteFile(filepath.Join(dir, "postgres", "seeded", "pgdata.tar.gz"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.RestoreData(context.Background(), env, "postgres", "seeded", dir); err != nil {
+		t.Fatalf("RestoreData: %v", err)
+	}
+
+	// Each start is followed by writing the service's secrets into its
+	// empty tmpfs, and only the service's.
+	var starts int
+	calls := rec.Calls()
+	for i, call := range calls {
+		if !strings.HasSuffix(call, " start envclone-myapp-postgres") {
+			continue
+		}
+		starts++
+		want := "exec -i -u 0 envclone-myapp-postgres sh -c"
+		if i+1 >= len(calls) || !strings.Contains(calls[i+1], want) || !strings.HasSuffix(calls[i+1], " /run/secrets/db_password") {
+			t.Errorf("call after %q = %q, want the db_password secret written", call, calls[i+1:])
+		}
+	}
+	if starts != 2 {
+		t.Errorf("postgres started %d times, want 2: %q", starts, calls)
+	}
+	for _, call := range calls {
+		if strings.Contains(call, "api_key") {
+			t.Errorf("secret of another container written: %s", call)
+		}
+	}
+}
+
+func TestResetDataVolumeInUse(t *testing.T) {
+	v := variants[0]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "data_reset.replay"))
+	env := &state.Environment{ProjectName: "myapp", ServiceIDs: []string{"postgres123"}}
+
+	err := mgr.ResetData(context.Background(), env, "postgres")
+	if err == nil || !strings.Contains(err.Error(), "volume is in use") {
+		t.Fatalf("ResetData = %v, want the volume removal error", err)
+	}
+	// The service is created again with its old volume, so the state
+	// records the new container rather than the removed one.
+	if len(env.ServiceIDs) != 1 || env.ServiceIDs[0] != "postgres456" {
+		t.Errorf("ServiceIDs = %v, want [postgres456]", env.ServiceIDs)
+	}
+	rec.Golden(t, filepath.Join("testdata", "data_reset.golden"))
+}
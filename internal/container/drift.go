@@ -0,0 +1,141 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+// Drift kinds.
+const (
+	DriftMissing = "missing" // recorded, but the container is gone
+	DriftStopped = "stopped" // recorded, but not running
+	DriftExtra   = "extra"   // labelled with the project, but not recorded
+	DriftChanged = "changed" // replaced or relabelled outside envclone
+)
+
+// Drift is a difference between the recorded environment and the
+// containers that actually exist, e.g. after a manual 'nerdctl rm' or a
+// reboot.
+type Drift struct {
+	Name   string
+	Role   string
+	Kind   string
+	Detail string
+}
+
+func (d Drift) String() string {
+	if d.Detail == "" {
+		return fmt.Sprintf("%s is %s", d.Name, d.Kind)
+	}
+	return fmt.Sprintf("%s is %s (%s)", d.Name, d.Kind, d.Detail)
+}
+
+// Drift compares the containers recorded in env with the ones labelled
+// with the project.
+func (m *Manager) Drift(ctx context.Context, env *state.Environment) ([]Drift, error) {
+	actual, err := m.projectContainers(ctx, env.ProjectName)
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+	matched := make(map[string]bool)
+
+	var drift []Drift
+	for _, rec := range recordedContainers(env) {
+		c, ok := findContainer(actual, rec)
+		if !ok {
+			drift = append(drift, Drift{Name: recordName(rec), Role: rec.Role, Kind: DriftMissing})
+			continue
+		}
+		matched[c.ID] = true
+
+		switch {
+		case rec.ID != "" && c.ID != rec.ID:
+			drift = append(drift, Drift{Name: c.Name, Role: rec.Role, Kind: DriftChanged,
+				Detail: fmt.Sprintf("recreated outside envclone: ID %s, recorded %s", shortID(c.ID), shortID(rec.ID))})
+		case rec.ConfigHash != "" && c.Labels[configHashLabel] != rec.ConfigHash:
+			drift = append(drift, Drift{Name: c.Name, Role: rec.Role, Kind: DriftChanged,
+				Detail: "labels differ from the recorded configuration"})
+		case !c.Running && !rec.Stopped:
+			drift = append(drift, Drift{Name: c.Name, Role: rec.Role, Kind: DriftStopped, Detail: c.State})
+		}
+	}
+
+	var extra []runtime.Container
+	for _, c := range actual {
+		if !matched[c.ID] {
+			extra = append(extra, c)
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i].Name < extra[j].Name })
+	for _, c := range extra {
+		drift = append(drift, Drift{Name: c.Name, Role: c.Labels["envclone.role"], Kind: DriftExtra,
+			Detail: "not part of the recorded environment"})
+	}
+	return drift, nil
+}
+
+// recordedContainers returns the containers env records. State from before
+// container records only has IDs, and names for the netns and dev
+// container.
+func recordedContainers(env *state.Environment) []state.ContainerRecord {
+	if len(env.Containers) > 0 {
+		return env.Containers
+	}
+	var recs []state.ContainerRecord
+	if env.NetNSID != "" {
+		recs = append(recs, state.ContainerRecord{Name: fmt.Sprintf("envclone-%s-netns", env.ProjectName), Role: "netns", ID: env.NetNSID})
+	}
+	for _, id := range env.ServiceIDs {
+		recs = append(recs, state.ContainerRecord{Role: "service", ID: id})
+	}
+	if env.DevContainerID != "" {
+		recs = append(recs, state.ContainerRecord{Name: fmt.Sprintf("envclone-%s-dev", env.ProjectName), Role: "dev", ID: env.DevContainerID})
+	}
+	return recs
+}
+
+// findContainer finds a recorded container by name, or by ID for records
+// without one.
+func findContainer(actual []runtime.Container, rec state.ContainerRecord) (runtime.Container, bool) {
+	for _, c := range actual {
+		if (rec.Name != "" && c.Name == rec.Name) || (rec.Name == "" && c.ID == rec.ID) {
+			return c, true
+		}
+	}
+	return runtime.Container{}, false
+}
+
+func recordName(rec state.ContainerRecord) string {
+	if rec.Name != "" {
+		return rec.Name
+	}
+	return fmt.Sprintf("%s container %s", rec.Role, shortID(rec.ID))
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// checkTarget returns a clear error if a container commands are about to
+// run in is missing or stopped, instead of the runtime's own.
+func (m *Manager) checkTarget(ctx context.Context, name, target string) error {
+	c, err := m.Runtime.Inspect(ctx, name)
+	if errors.Is(err, runtime.ErrNotFound) {
+		return fmt.Errorf("%s no longer exists (removed outside envclone?); run 'envclone repair' to recreate it", name)
+	}
+	if err != nil {
+		return err
+	}
+	if !c.Running {
+		return fmt.Errorf("%s is not running (%s); run 'envclone start %s' or 'envclone repair'", name, c.State, target)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package container
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/state"
+)
+
+func TestDrift(t *testing.T) {
+	v := variants[0]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+	rec.Replay = runningReplay(t, mgr)
+
+	env := &state.Environment{
+		ProjectName: "myapp",
+		Containers: []state.ContainerRecord{
+			{Name: "envclone-myapp-netns", Role: "netns", ID: "netns1"},
+			{Name: "envclone-myapp-postgres", Role: "service", ID: "postgres0"},
+			{Name: "envclone-myapp-mailpit", Role: "service", ID: "mailpit1"},
+			{Name: "envclone-myapp-dev", Role: "dev", ID: "dev1", ConfigHash: "000000000000"},
+		},
+	}
+
+	drift, err := mgr.Drift(context.Background(), env)
+	if err != nil {
+		t.Fatalf("Drift: %v", err)
+	}
+	var got []string
+	for _, d := range drift {
+		got = append(got, d.Kind+" "+d.Name)
+	}
+	want := []string{
+		"changed envclone-myapp-postgres",
+		"missing envclone-myapp-mailpit",
+		"changed envclone-myapp-dev",
+		"extra envclone-myapp-redis",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("drift:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDriftFromIDsOnly(t *testing.T) {
+	v := variants[0]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+	rec.Replay = runningReplay(t, mgr)
+
+	// State migrated from before container records.
+	env := &state.Environment{
+		ProjectName:    "myapp",
+		NetNSID:        "netns1",
+		ServiceIDs:     []string{"postgres1", "redis1"},
+		DevContainerID: "dev1",
+	}
+	drift, err := mgr.Drift(context.Background(), env)
+	if err != nil {
+		t.Fatalf("Drift: %v", err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("drift = %v, want none", drift)
+	}
+}
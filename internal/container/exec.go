@@ -0,0 +1,94 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+// Exec runs a command in the target container, attached to the caller's
+// stdio. In the dev container, the user defaults to remoteUser. The
+// returned error wraps the process's *exec.ExitError when the command
+// itself failed, so its exit code can be propagated.
+func (m *Manager) Exec(ctx context.Context, env *state.Environment, target string, opts runtime.ExecOptions, command []string) error {
+	containerName, err := m.targetContainer(ctx, env, target)
+	if err != nil {
+		return err
+	}
+
+	if target == "" || target == DevTarget {
+		if opts.User == "" {
+			opts.User = env.RemoteUser
+		}
+		// The probed login environment goes first so -e can override it.
+		opts.Env = append(append([]string{}, env.UserEnv...), opts.Env...)
+	}
+
+	return m.Runtime.ExecInteractive(ctx, containerName, opts, command...)
+}
+
+// MapWorkdir translates a host directory inside the environment's workspace
+// folder to the matching path under the workspace mount. It reports false
+// when hostDir is outside the workspace.
+func MapWorkdir(env *state.Environment, hostDir string) (string, bool) {
+	hostRoot := env.WorkspaceFolder
+	if hostRoot == "" {
+		hostRoot = env.ProjectDir
+	}
+	containerRoot := env.WorkspaceMount
+	if containerRoot == "" {
+		containerRoot = "/workspace"
+	}
+
+	rel, err := filepath.Rel(evalSymlinks(hostRoot), evalSymlinks(hostDir))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return path.Join(containerRoot, filepath.ToSlash(rel)), true
+}
+
+func evalSymlinks(p string) string {
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+	return filepath.Clean(p)
+}
+
+// ParseEnvFile reads KEY=VALUE lines from an env file. Blank lines and lines
+// starting with # are skipped, and a bare KEY takes its value from the host
+// environment, as with docker's --env-file.
+func ParseEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading env file: %w", err)
+	}
+	defer f.Close()
+
+	var env []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.Contains(line, "=") {
+			value, ok := os.LookupEnv(line)
+			if !ok {
+				continue
+			}
+			line += "=" + value
+		}
+		env = append(env, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading env file: %w", err)
+	}
+	return env, nil
+}
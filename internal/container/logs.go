@@ -0,0 +1,183 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+// LifecycleTarget names the captured lifecycle command output in Logs.
+const LifecycleTarget = "lifecycle"
+
+// LogOptions controls which log lines Logs shows and how.
+type LogOptions struct {
+	runtime.LogOptions
+	Color bool
+}
+
+// logColors cycle through the containers like compose does.
+var logColors = []string{"36", "33", "32", "35", "34", "96", "93", "92", "95", "94"}
+
+// Logs writes the logs of the given targets to w, each line prefixed with
+// the target name. Targets are service names, "dev" and LifecycleTarget;
+// with none, every container in the environment plus the lifecycle output
+// is shown.
+func (m *Manager) Logs(ctx context.Context, env *state.Environment, targets []string, opts LogOptions, w io.Writer) error {
+	containers, showLifecycle, err := m.logTargets(ctx, env, targets)
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	names := append([]string{}, containers...)
+	if showLifecycle {
+		names = append(names, LifecycleTarget)
+	}
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+
+	var mu sync.Mutex
+	newWriter := func(i int, name string) *prefixWriter {
+		prefix := fmt.Sprintf("%-*s | ", width, name)
+		if opts.Color {
+			prefix = fmt.Sprintf("\x1b[%sm%s\x1b[0m", logColors[i%len(logColors)], prefix)
+		}
+		return &prefixWriter{mu: &mu, w: w, prefix: prefix}
+	}
+
+	if showLifecycle {
+		pw := newWriter(len(containers), LifecycleTarget)
+		if err := m.writeLifecycleLog(pw, opts.Tail); err != nil {
+			return err
+		}
+		pw.Flush()
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(containers))
+	for i, target := range containers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pw := newWriter(i, target)
+			defer pw.Flush()
+
+			name := fmt.Sprintf("envclone-%s-%s", env.ProjectName, target)
+			if err := m.Runtime.Logs(ctx, name, opts.LogOptions, pw); err != nil && ctx.Err() == nil {
+				errs[i] = fmt.Errorf("%s: %w", target, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logTargets resolves the requested targets into container suffixes and
+// whether the lifecycle log was asked for.
+func (m *Manager) logTargets(ctx context.Context, env *state.Environment, targets []string) ([]string, bool, error) {
+	var containers []string
+	showLifecycle := len(targets) == 0
+	for _, target := range targets {
+		if target == LifecycleTarget {
+			showLifecycle = true
+		} else {
+			containers = append(containers, target)
+		}
+	}
+	if len(containers) == 0 && len(targets) > 0 {
+		return nil, showLifecycle, nil
+	}
+
+	containers, err := m.resolveTargets(ctx, env, containers)
+	if err != nil {
+		return nil, false, err
+	}
+	return containers, showLifecycle, nil
+}
+
+// writeLifecycleLog copies the captured lifecycle output, or its last tail
+// lines, to w.
+func (m *Manager) writeLifecycleLog(w io.Writer, tail int) error {
+	path, err := state.LifecycleLog(m.ProjectDir)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading lifecycle log: %w", err)
+	}
+	if tail >= 0 && len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
+
+// prefixWriter writes complete lines to w with a prefix, holding partial
+// lines back until they are finished so output from several containers
+// never interleaves mid-line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes out a trailing line that has no newline.
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.writeLine(append(p.buf, '\n'))
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := fmt.Fprintf(p.w, "%s%s", p.prefix, line)
+	return err
+}
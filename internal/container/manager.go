@@ -2,113 +2,324 @@ package container
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/matoval/envclone/internal/config"
-	"github.com/matoval/envclone/internal/exec"
-	"github.com/matoval/envclone/internal/network"
 	"github.com/matoval/envclone/internal/platform"
+	"github.com/matoval/envclone/internal/runtime"
 	"github.com/matoval/envclone/internal/state"
+	"github.com/matoval/envclone/internal/version"
 )
 
+// ContainerInfo is a container of the environment and its envclone role.
 type ContainerInfo struct {
-	Name   string
-	Role   string
-	Status string
+	runtime.Container
+	Role string
 }
 
 type Manager struct {
 	Platform   platform.Platform
-	Runner     *exec.Runner
+	Runtime    runtime.Runtime
 	Config     *config.DevContainer
 	ProjectDir string
+
+	// KeepOnFailure leaves whatever Up managed to create in place when a
+	// step fails, instead of rolling it back, so it can be debugged.
+	KeepOnFailure bool
+
+	// FromSnapshot starts the dev container from the snapshot with this
+	// tag instead of the configured image or Dockerfile.
+	FromSnapshot string
+
+	// Previous is the environment's state before Up, if any. Up carries
+	// over what it records about containers it keeps.
+	Previous *state.Environment
+
+	// secrets caches the values of the configured secrets.
+	secrets map[string]string
+
+	// host caches the container host's capacity, once hostChecked.
+	host        *runtime.Info
+	hostChecked bool
 }
 
 func (m *Manager) projectName() string {
 	return filepath.Base(m.ProjectDir)
 }
 
-func (m *Manager) Up(ctx context.Context) (*state.Environment, error) {
+// Up brings the environment in line with devcontainer.json, following
+// Plan: containers that still match are kept, the rest are created,
+// recreated or removed. It is transactional for what it creates: if any
+// step fails or ctx is cancelled, the containers created so far are
+// removed again and a nil environment is returned. Containers it removed
+// to recreate them cannot be brought back, so then Previous is returned
+// without them instead, marked as failed, for the caller to record until
+// 'repair'. With KeepOnFailure set, the partially created environment is
+// returned alongside the error, marked as failed, so the caller can record
+// it for 'down' and 'status'.
+func (m *Manager) Up(ctx context.Context) (env *state.Environment, err error) {
 	name := m.projectName()
 
-	// Clean up any existing containers for this project
-	m.removeExisting(ctx, name)
+	plan, err := m.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteUser := m.Config.RemoteUser
+	if remoteUser == "" {
+		remoteUser = "root"
+	}
 
-	// Build image from Dockerfile if configured
-	if m.Config.Build != nil {
-		if err := m.buildImage(ctx, name); err != nil {
-			return nil, fmt.Errorf("building image: %w", err)
+	hostPath, containerPath := m.workspacePaths()
+	now := time.Now()
+	env = &state.Environment{
+		EnvcloneVersion: version.String(),
+		ProjectName:     name,
+		ProjectDir:      m.ProjectDir,
+		Runtime:         m.Runtime.Name(),
+		SSHPort:         m.Platform.SSHPort(),
+		RemoteUser:      remoteUser,
+		WorkspaceFolder: hostPath,
+		WorkspaceMount:  containerPath,
+		Status:          state.StatusRunning,
+		CreatedAt:       now,
+		StartedAt:       now,
+		ConfigFile:      config.Path(m.ProjectDir),
+		ConfigHash:      m.Config.Fingerprint(),
+		FeatureDigests:  m.Config.FeatureDigests(),
+		Snapshot:        m.FromSnapshot,
+	}
+
+	var created []string
+	var removed []Change
+	defer func() {
+		if err == nil {
+			return
+		}
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w (interrupted)", err)
+		}
+		if m.KeepOnFailure {
+			env.Status = state.StatusFailed
+			env.Error = err.Error()
+			return
+		}
+		// The original context may already be cancelled (Ctrl-C), so the
+		// rollback runs on one that isn't.
+		fmt.Println("Rolling back...")
+		m.Runtime.Remove(context.WithoutCancel(ctx), created...)
+		env = nil
+		if len(removed) > 0 && m.Previous != nil {
+			env = withoutContainers(m.Previous, removed)
+			env.Status = state.StatusFailed
+			env.Error = err.Error()
+		}
+	}()
+
+	// Remove containers that are gone from the config or about to be
+	// recreated, so their names are free. Going backwards removes the
+	// containers joining the network namespace before the namespace.
+	for i := len(plan.Changes) - 1; i >= 0; i-- {
+		if c := plan.Changes[i]; c.Action == ActionRemove || c.Action == ActionRecreate {
+			if err := m.Runtime.Remove(ctx, c.Name); err != nil {
+				return env, fmt.Errorf("removing %s: %w", c.Name, err)
+			}
+			removed = append(removed, c)
 		}
 	}
 
-	// Create shared network namespace with SSH port published
-	netNSID, err := network.CreateNetNS(ctx, m.Runner, m.Platform, name, m.Platform.SSHPort())
-	if err != nil {
-		return nil, err
+	devCreated := false
+	for _, c := range plan.Changes {
+		id := c.ID
+		switch c.Action {
+		case ActionRemove:
+			continue
+		case ActionKeep:
+			// Rewrite the secrets in case their values changed.
+			if err := m.writeSecrets(ctx, c.Name, c.spec.secrets); err != nil {
+				return env, err
+			}
+			m.keepContainer(ctx, env, c)
+		case ActionCreate, ActionRecreate:
+			if c.Role == "dev" {
+				// Build image from Dockerfile if configured
+				if m.Config.Build != nil && m.FromSnapshot == "" {
+					if err := m.buildImage(ctx, name); err != nil {
+						return env, fmt.Errorf("building image: %w", err)
+					}
+				}
+				devCreated = true
+			}
+			if id, err = m.createContainer(ctx, name, c.spec); err != nil {
+				return env, fmt.Errorf("creating %s: %w", c.Name, err)
+			}
+			created = append(created, c.Name)
+			m.recordContainer(ctx, env, c.spec, id)
+		}
+
+		switch c.Role {
+		case "netns":
+			env.NetNSID = id
+			env.Ports = c.spec.opts.Ports
+		case "service":
+			env.ServiceIDs = append(env.ServiceIDs, id)
+		case "dev":
+			env.DevContainerID = id
+		}
 	}
-	netNSContainer := fmt.Sprintf("envclone-%s-netns", name)
 
-	// Create service containers
-	var serviceIDs []string
-	for _, svc := range m.Config.Services {
-		id, err := m.createService(ctx, name, netNSContainer, svc)
-		if err != nil {
-			return nil, fmt.Errorf("creating service %s: %w", svc.Name, err)
+	// Pick up the user's shell and the environment their profile sets up,
+	// so exec and lifecycle commands see the same PATH as a login shell
+	m.probeUserEnv(ctx, env)
+
+	// Run lifecycle commands for a new dev container, capturing their
+	// output for 'envclone logs'
+	if devCreated {
+		lifecycleLog := m.openLifecycleLog(false)
+		defer lifecycleLog.Close()
+		m.runLifecycleCommand(ctx, env, "postCreateCommand", m.Config.PostCreateCommand, lifecycleLog)
+		m.runLifecycleCommand(ctx, env, "postStartCommand", m.Config.PostStartCommand, lifecycleLog)
+	}
+
+	// A cancellation during the lifecycle commands above only surfaces as
+	// warnings; treat it as a failed up all the same.
+	if err := ctx.Err(); err != nil {
+		return env, err
+	}
+
+	return env, nil
+}
+
+// withoutContainers returns a copy of prev that no longer records the
+// removed containers.
+func withoutContainers(prev *state.Environment, removed []Change) *state.Environment {
+	env := *prev
+	gone := func(name, id string) bool {
+		for _, c := range removed {
+			if c.Name == name || (id != "" && c.ID == id) {
+				return true
+			}
 		}
-		serviceIDs = append(serviceIDs, id)
+		return false
 	}
 
-	// Create dev container
-	devID, err := m.createDevContainer(ctx, name, netNSContainer)
-	if err != nil {
-		return nil, fmt.Errorf("creating dev container: %w", err)
+	env.Containers = nil
+	for _, rec := range prev.Containers {
+		if !gone(rec.Name, rec.ID) {
+			env.Containers = append(env.Containers, rec)
+		}
+	}
+	env.ServiceIDs = nil
+	for _, id := range prev.ServiceIDs {
+		if !gone("", id) {
+			env.ServiceIDs = append(env.ServiceIDs, id)
+		}
 	}
+	if gone("", env.NetNSID) {
+		env.NetNSID = ""
+	}
+	if gone("", env.DevContainerID) {
+		env.DevContainerID = ""
+	}
+	return &env
+}
 
-	// Run postCreateCommand if set
-	if m.Config.PostCreateCommand != "" {
-		devContainer := fmt.Sprintf("envclone-%s-dev", name)
-		args := m.Platform.NerdctlArgs("exec", devContainer, "sh", "-c", m.Config.PostCreateCommand)
-		if _, err := m.Runner.Run(ctx, args[0], args[1:]...); err != nil {
-			fmt.Printf("Warning: postCreateCommand failed: %v\n", err)
+// recordContainer records a container created from spec in env, with the
+// digest of the image it was created from.
+func (m *Manager) recordContainer(ctx context.Context, env *state.Environment, spec *containerSpec, id string) {
+	rec := state.ContainerRecord{
+		Name:       spec.opts.Name,
+		Role:       spec.role,
+		ID:         id,
+		Image:      spec.opts.Image,
+		ConfigHash: spec.hash,
+	}
+	if img, err := m.Runtime.InspectImage(ctx, spec.opts.Image); err == nil {
+		rec.ImageID = img.ID
+	}
+	env.SetContainer(rec)
+}
+
+// keepContainer carries over what the previous state recorded about a
+// container up keeps: its record and, for the network namespace and the
+// dev container, when the environment was created and the lifecycle
+// commands that ran in it.
+func (m *Manager) keepContainer(ctx context.Context, env *state.Environment, c Change) {
+	prev := m.Previous
+	rec, ok := state.ContainerRecord{}, false
+	if prev != nil {
+		rec, ok = prev.Container(c.Name)
+	}
+	if !ok || rec.ID != c.ID {
+		m.recordContainer(ctx, env, c.spec, c.ID)
+		return
+	}
+	env.SetContainer(rec)
+	switch c.Role {
+	case "netns":
+		if !prev.CreatedAt.IsZero() {
+			env.CreatedAt = prev.CreatedAt
 		}
+	case "dev":
+		env.Lifecycle = prev.Lifecycle
 	}
+}
 
-	// Run postStartCommand if set
-	if m.Config.PostStartCommand != "" {
-		devContainer := fmt.Sprintf("envclone-%s-dev", name)
-		args := m.Platform.NerdctlArgs("exec", devContainer, "sh", "-c", m.Config.PostStartCommand)
-		if _, err := m.Runner.Run(ctx, args[0], args[1:]...); err != nil {
-			fmt.Printf("Warning: postStartCommand failed: %v\n", err)
+// openLifecycleLog opens the project's lifecycle log, truncating it unless
+// appending. Failing to open it only costs the captured output, so it is
+// not fatal.
+func (m *Manager) openLifecycleLog(appending bool) io.WriteCloser {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appending {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	path, err := state.LifecycleLog(m.ProjectDir)
+	if err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(path, flags, 0o644); err == nil {
+			return f
 		}
 	}
+	fmt.Printf("Warning: lifecycle output will not be captured: %v\n", err)
+	return nopWriteCloser{io.Discard}
+}
 
-	remoteUser := m.Config.RemoteUser
-	if remoteUser == "" {
-		remoteUser = "root"
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// runLifecycleCommand runs one of the devcontainer.json lifecycle commands
+// in the dev container. Failures are reported but do not fail up.
+func (m *Manager) runLifecycleCommand(ctx context.Context, env *state.Environment, label, command string, w io.Writer) {
+	if command == "" {
+		return
 	}
+	fmt.Fprintf(w, "==> %s: %s\n", label, command)
 
-	return &state.Environment{
-		ProjectName:    name,
-		ProjectDir:     m.ProjectDir,
-		DevContainerID: devID,
-		NetNSID:        netNSID,
-		ServiceIDs:     serviceIDs,
-		SSHPort:        m.Platform.SSHPort(),
-		RemoteUser:     remoteUser,
-	}, nil
+	devContainer := fmt.Sprintf("envclone-%s-dev", env.ProjectName)
+	opts := runtime.ExecOptions{Env: env.UserEnv}
+	err := m.Runtime.ExecStream(ctx, devContainer, opts, w, "sh", "-c", command)
+	rec := state.LifecycleRecord{Command: command, Finished: time.Now()}
+	if err != nil {
+		rec.Error = err.Error()
+		fmt.Fprintf(w, "==> %s failed: %v\n", label, err)
+		fmt.Printf("Warning: %s failed: %v (see 'envclone logs lifecycle')\n", label, err)
+	}
+	if env.Lifecycle == nil {
+		env.Lifecycle = make(map[string]state.LifecycleRecord)
+	}
+	env.Lifecycle[label] = rec
 }
 
 func (m *Manager) buildImage(ctx context.Context, projectName string) error {
 	tag := fmt.Sprintf("envclone-%s:latest", projectName)
-	dockerfilePath := m.Config.Build.Dockerfile
-
-	// Resolve relative Dockerfile path against the .devcontainer directory
-	if !filepath.IsAbs(dockerfilePath) {
-		dockerfilePath = filepath.Join(m.ProjectDir, ".devcontainer", dockerfilePath)
-	}
+	dockerfilePath := m.dockerfilePath()
 
 	buildContext := filepath.Dir(dockerfilePath)
 	if m.Config.Build.Context != "" {
@@ -119,94 +330,53 @@ func (m *Manager) buildImage(ctx context.Context, projectName string) error {
 	}
 
 	fmt.Printf("Building image %s from %s...\n", tag, dockerfilePath)
-	args := m.Platform.NerdctlArgs("build", "-t", tag, "-f", dockerfilePath, buildContext)
-	_, err := m.Runner.Run(ctx, args[0], args[1:]...)
-	return err
+	return m.Runtime.Build(ctx, runtime.BuildOptions{
+		Tag:        tag,
+		Dockerfile: dockerfilePath,
+		Context:    buildContext,
+	})
 }
 
-func (m *Manager) createDevContainer(ctx context.Context, projectName, netNSContainer string) (string, error) {
-	containerName := fmt.Sprintf("envclone-%s-dev", projectName)
-
-	// Determine host source path and container mount target
-	hostPath := m.ProjectDir
+// workspacePaths returns the host directory mounted into the dev container
+// and where it is mounted.
+func (m *Manager) workspacePaths() (hostPath, containerPath string) {
+	hostPath = m.ProjectDir
 	if m.Config.WorkspaceFolder != "" {
 		hostPath = m.Config.WorkspaceFolder
 	}
-	containerPath := "/workspace"
+	containerPath = "/workspace"
 	if m.Config.WorkspaceMount != "" {
 		containerPath = m.Config.WorkspaceMount
 	}
+	return hostPath, containerPath
+}
 
-	mountArgs := m.Platform.MountArgs(hostPath, containerPath)
-
-	args := m.Platform.NerdctlArgs("run", "-d",
-		"--name", containerName,
-		"--label", fmt.Sprintf("envclone.project=%s", projectName),
-		"--label", "envclone.role=dev",
-		"--network", fmt.Sprintf("container:%s", netNSContainer),
-	)
-	args = append(args, mountArgs...)
-
-	// Apply additional mounts from devcontainer.json, expanding ${localEnv:VAR} references
-	for _, mount := range m.Config.Mounts {
-		expanded := expandLocalEnv(mount)
-		args = append(args, "-v", expanded)
-	}
-
-	args = append(args, "-w", containerPath, "--init")
-	args = append(args, m.Config.RunArgs...)
-
-	image := m.Config.Image
-	if m.Config.Build != nil {
-		image = fmt.Sprintf("envclone-%s:latest", projectName)
-	}
-	args = append(args, image, "sleep", "infinity")
-
-	id, err := m.Runner.Run(ctx, args[0], args[1:]...)
+func (m *Manager) createDevContainer(ctx context.Context, env *state.Environment, netNSContainer string) (string, error) {
+	m.hostInfo(ctx)
+	spec, err := m.devSpec(env.ProjectName, netNSContainer)
 	if err != nil {
 		return "", err
 	}
-	return id, nil
+	return m.createRecorded(ctx, env, spec)
 }
 
-func (m *Manager) createService(ctx context.Context, projectName, netNSContainer string, svc config.ServiceConfig) (string, error) {
-	containerName := fmt.Sprintf("envclone-%s-%s", projectName, svc.Name)
-
-	args := m.Platform.NerdctlArgs("run", "-d",
-		"--name", containerName,
-		"--label", fmt.Sprintf("envclone.project=%s", projectName),
-		"--label", "envclone.role=service",
-		"--network", fmt.Sprintf("container:%s", netNSContainer),
-	)
-
-	for _, env := range svc.Env {
-		args = append(args, "-e", env)
-	}
-
-	for _, vol := range svc.Volumes {
-		args = append(args, "-v", vol)
-	}
-
-	args = append(args, svc.Image)
+func (m *Manager) createService(ctx context.Context, env *state.Environment, netNSContainer string, svc config.ServiceConfig) (string, error) {
+	return m.createRecorded(ctx, env, m.serviceSpec(env.ProjectName, netNSContainer, svc))
+}
 
-	id, err := m.Runner.Run(ctx, args[0], args[1:]...)
+// createRecorded creates a container outside of up and records it in env.
+func (m *Manager) createRecorded(ctx context.Context, env *state.Environment, spec *containerSpec) (string, error) {
+	id, err := m.createContainer(ctx, env.ProjectName, spec)
 	if err != nil {
 		return "", err
 	}
+	m.recordContainer(ctx, env, spec, id)
 	return id, nil
 }
 
-func (m *Manager) removeExisting(ctx context.Context, projectName string) {
-	args := m.Platform.NerdctlArgs("ps", "-a", "--filter", fmt.Sprintf("label=envclone.project=%s", projectName), "--format", "{{.ID}}")
-	out, err := m.Runner.Run(ctx, args[0], args[1:]...)
-	if err != nil || out == "" {
-		return
-	}
-	ids := strings.Fields(out)
-	if len(ids) > 0 {
-		rmArgs := m.Platform.NerdctlArgs(append([]string{"rm", "-f"}, ids...)...)
-		m.Runner.Run(ctx, rmArgs[0], rmArgs[1:]...)
-	}
+// projectContainers lists all containers labelled with the project.
+func (m *Manager) projectContainers(ctx context.Context, projectName string) ([]runtime.Container, error) {
+	return m.Runtime.List(ctx, fmt.Sprintf("label=envclone.project=%s", projectName))
 }
 
 // expandLocalEnv replaces ${localEnv:VAR} references with values from the host environment.
@@ -227,83 +397,97 @@ func expandLocalEnv(s string) string {
 	return s
 }
 
-// IsRunning checks if the dev container is currently running.
+// IsRunning checks if the dev container is currently running. A missing
+// container is not running; other inspect failures are returned.
 func (m *Manager) IsRunning(ctx context.Context, env *state.Environment) (bool, error) {
 	devContainer := fmt.Sprintf("envclone-%s-dev", env.ProjectName)
-	args := m.Platform.NerdctlArgs("inspect", "--format", "{{.State.Running}}", devContainer)
-	out, err := m.Runner.Run(ctx, args[0], args[1:]...)
-	if err != nil {
+	c, err := m.Runtime.Inspect(ctx, devContainer)
+	if errors.Is(err, runtime.ErrNotFound) {
 		return false, nil
 	}
-	return strings.TrimSpace(out) == "true", nil
+	if err != nil {
+		return false, err
+	}
+	return c.Running, nil
 }
 
-func (m *Manager) Down(ctx context.Context, env *state.Environment) error {
+// Down removes all containers of the environment. Named volumes are kept so
+// service data survives, unless removeVolumes is set.
+func (m *Manager) Down(ctx context.Context, env *state.Environment, removeVolumes bool) error {
 	// Stop and remove all containers by label
-	name := env.ProjectName
-	args := m.Platform.NerdctlArgs("ps", "-a", "--filter", fmt.Sprintf("label=envclone.project=%s", name), "--format", "{{.ID}}")
-	out, err := m.Runner.Run(ctx, args[0], args[1:]...)
+	containers, err := m.projectContainers(ctx, env.ProjectName)
 	if err != nil {
 		return fmt.Errorf("listing containers: %w", err)
 	}
 
-	ids := strings.Fields(out)
-	if len(ids) > 0 {
-		rmArgs := m.Platform.NerdctlArgs(append([]string{"rm", "-f"}, ids...)...)
-		if _, err := m.Runner.Run(ctx, rmArgs[0], rmArgs[1:]...); err != nil {
-			return fmt.Errorf("removing containers: %w", err)
+	var ids []string
+	for _, c := range containers {
+		ids = append(ids, c.ID)
+	}
+	if err := m.Runtime.Remove(ctx, ids...); err != nil {
+		return fmt.Errorf("removing containers: %w", err)
+	}
+
+	if removeVolumes {
+		if err := m.RemoveVolumes(ctx); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (m *Manager) Shell(ctx context.Context, env *state.Environment) error {
-	devContainer := fmt.Sprintf("envclone-%s-dev", env.ProjectName)
-	args := m.Platform.NerdctlArgs("exec", "-it", devContainer, "/bin/bash")
-	return m.Runner.RunInteractive(ctx, args[0], args[1:]...)
+// targetContainer returns the container name for a target accepted by
+// Shell and Exec: a service name, or "" or "dev" for the dev container.
+func (m *Manager) targetContainer(ctx context.Context, env *state.Environment, target string) (string, error) {
+	if target == "" {
+		target = DevTarget
+	} else if _, err := m.resolveTargets(ctx, env, []string{target}); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("envclone-%s-%s", env.ProjectName, target)
+	if err := m.checkTarget(ctx, name, target); err != nil {
+		return "", err
+	}
+	return name, nil
 }
 
-func (m *Manager) Exec(ctx context.Context, env *state.Environment, command []string) error {
-	devContainer := fmt.Sprintf("envclone-%s-dev", env.ProjectName)
-	nerdctlArgs := []string{"exec", devContainer}
-	nerdctlArgs = append(nerdctlArgs, command...)
-	args := m.Platform.NerdctlArgs(nerdctlArgs...)
-	return m.Runner.RunInteractive(ctx, args[0], args[1:]...)
+// shellFallback starts bash if the image has it and sh otherwise, since
+// many service images are Alpine or distroless-like.
+const shellFallback = "if command -v bash >/dev/null 2>&1; then exec bash; else exec sh; fi"
+
+// Shell opens an interactive shell in the target container. In the dev
+// container it is the remote user's login shell, started in workdir or,
+// if that is empty, the workspace mount.
+func (m *Manager) Shell(ctx context.Context, env *state.Environment, target, workdir string) error {
+	opts := runtime.ExecOptions{Interactive: true, TTY: true}
+	command := []string{"/bin/sh", "-c", shellFallback}
+
+	if target == "" || target == DevTarget {
+		opts.Workdir = workdir
+		if opts.Workdir == "" {
+			opts.Workdir = env.WorkspaceMount
+		}
+		if env.Shell != "" {
+			command = []string{env.Shell, "-l"}
+		}
+	}
+	return m.Exec(ctx, env, target, opts, command)
 }
 
 func (m *Manager) Status(ctx context.Context, env *state.Environment) ([]ContainerInfo, error) {
-	args := m.Platform.NerdctlArgs("ps", "-a",
-		"--filter", fmt.Sprintf("label=envclone.project=%s", env.ProjectName),
-		"--format", "{{.Names}}\t{{.Labels}}\t{{.Status}}",
-	)
-	out, err := m.Runner.Run(ctx, args[0], args[1:]...)
+	containers, err := m.projectContainers(ctx, env.ProjectName)
 	if err != nil {
 		return nil, err
 	}
 
 	var infos []ContainerInfo
-	for _, line := range strings.Split(out, "\n") {
-		if line == "" {
-			continue
-		}
-		parts := strings.SplitN(line, "\t", 3)
-		if len(parts) < 3 {
-			continue
-		}
-		role := "unknown"
-		if strings.Contains(parts[1], "envclone.role=dev") {
-			role = "dev"
-		} else if strings.Contains(parts[1], "envclone.role=service") {
-			role = "service"
-		} else if strings.Contains(parts[1], "envclone.role=netns") {
-			role = "netns"
+	for _, c := range containers {
+		role := c.Labels["envclone.role"]
+		if role == "" {
+			role = "unknown"
 		}
-		infos = append(infos, ContainerInfo{
-			Name:   parts[0],
-			Role:   role,
-			Status: parts[2],
-		})
+		infos = append(infos, ContainerInfo{Container: c, Role: role})
 	}
 	return infos, nil
 }
@@ -0,0 +1,125 @@
+package container
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/exec"
+	"github.com/matoval/envclone/internal/exec/exectest"
+	"github.com/matoval/envclone/internal/platform"
+	"github.com/matoval/envclone/internal/runtime"
+)
+
+// variants are the platform and runtime combinations Up is tested with.
+var variants = []struct {
+	name       string
+	platform   platform.Platform
+	newRuntime func(plat platform.Platform, runner exec.Runner) runtime.Runtime
+}{
+	{"linux-nerdctl", &platform.Linux{}, runtime.NewNerdctl},
+	{"darwin-nerdctl", &platform.Darwin{}, runtime.NewNerdctl},
+	{"linux-docker", &platform.Linux{}, func(_ platform.Platform, r exec.Runner) runtime.Runtime { return runtime.NewDocker(r) }},
+	{"linux-podman", &platform.Linux{}, func(_ platform.Platform, r exec.Runner) runtime.Runtime { return runtime.NewPodman(r) }},
+}
+
+func testConfig() *config.DevContainer {
+	return &config.DevContainer{
+		Image:             "mcr.microsoft.com/devcontainers/base:ubuntu",
+		RemoteUser:        "dev",
+		PostCreateCommand: "make deps",
+		Mounts:            []string{"${localEnv:ENVCLONE_TEST_GITCONFIG}:/home/dev/.gitconfig"},
+		RunArgs:           []string{"--cap-add=SYS_PTRACE"},
+		Services: []config.ServiceConfig{{
+			Name:    "postgres",
+			Image:   "postgres:16",
+			Env:     []string{"POSTGRES_PASSWORD=dev"},
+			Volumes: []string{"pgdata:/var/lib/postgresql/data"},
+		}},
+	}
+}
+
+// newTestManager returns a manager for project "myapp" whose commands are
+// recorded and answered from the replay fixture.
+func newTestManager(t *testing.T, plat platform.Platform, newRuntime func(platform.Platform, exec.Runner) runtime.Runtime, fixture string) (*Manager, *exectest.Recorder) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("ENVCLONE_TEST_GITCONFIG", "/home/me/.gitconfig")
+
+	rec := &exectest.Recorder{Replay: exectest.LoadReplay(t, fixture)}
+	return &Manager{
+		Platform:   plat,
+		Runtime:    newRuntime(plat, rec),
+		Config:     testConfig(),
+		ProjectDir: "/src/myapp",
+	}, rec
+}
+
+func TestUp(t *testing.T) {
+	for _, v := range variants {
+		t.Run(v.name, func(t *testing.T) {
+			mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+
+			env, err := mgr.Up(context.Background())
+			if err != nil {
+				t.Fatalf("Up: %v", err)
+			}
+
+			if env.NetNSID != "netns123" || env.DevContainerID != "dev123" {
+				t.Errorf("container IDs = %s, %s; want netns123, dev123", env.NetNSID, env.DevContainerID)
+			}
+			if len(env.ServiceIDs) != 1 || env.ServiceIDs[0] != "postgres123" {
+				t.Errorf("ServiceIDs = %v, want [postgres123]", env.ServiceIDs)
+			}
+			if env.Shell != "/bin/bash" {
+				t.Errorf("Shell = %q, want /bin/bash", env.Shell)
+			}
+			if env.Runtime != mgr.Runtime.Name() || env.Failed() {
+				t.Errorf("Runtime, Status = %q, %q", env.Runtime, env.Status)
+			}
+			if rec, ok := env.Container("envclone-myapp-postgres"); !ok || rec.ID != "postgres123" || rec.ImageID != "sha256:9b1f6e3a0c2d4e5f" {
+				t.Errorf("postgres record = %+v, want its ID and image digest", rec)
+			}
+			if len(env.Containers) != 3 || len(env.Ports) != 1 || env.Lifecycle["postCreateCommand"].Finished.IsZero() {
+				t.Errorf("Containers, Ports, Lifecycle = %+v, %v, %+v", env.Containers, env.Ports, env.Lifecycle)
+			}
+
+			rec.Golden(t, filepath.Join("testdata", "up_"+v.name+".golden"))
+		})
+	}
+}
+
+func TestUpRollsBackOnFailure(t *testing.T) {
+	v := variants[0]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up_rollback.replay"))
+
+	env, err := mgr.Up(context.Background())
+	if err == nil {
+		t.Fatal("Up succeeded, want an error")
+	}
+	if env != nil {
+		t.Errorf("Up returned an environment after rolling back: %+v", env)
+	}
+
+	rec.Golden(t, filepath.Join("testdata", "up_rollback.golden"))
+}
+
+func TestUpKeepOnFailure(t *testing.T) {
+	v := variants[0]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up_rollback.replay"))
+	mgr.KeepOnFailure = true
+
+	env, err := mgr.Up(context.Background())
+	if err == nil {
+		t.Fatal("Up succeeded, want an error")
+	}
+	if env == nil || !env.Failed() || env.NetNSID != "netns123" {
+		t.Fatalf("Up returned %+v, want the failed environment", env)
+	}
+	calls := rec.Calls()
+	if last := calls[len(calls)-1]; !strings.HasPrefix(last, "nerdctl run -d --name envclone-myapp-dev ") {
+		t.Errorf("last command = %s, want the failed dev container run", last)
+	}
+}
@@ -0,0 +1,292 @@
+package container
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/network"
+	"github.com/matoval/envclone/internal/runtime"
+)
+
+// configHashLabel records the hash of the spec a container was created
+// from, so up can tell which containers still match devcontainer.json.
+const configHashLabel = "envclone.config-hash"
+
+// Plan actions.
+const (
+	ActionCreate   = "create"
+	ActionRecreate = "recreate"
+	ActionKeep     = "keep"
+	ActionRemove   = "remove"
+)
+
+// Change is what up will do with one container.
+type Change struct {
+	Name   string
+	Role   string
+	Action string
+	Reason string // why a container is recreated or removed
+
+	// ID is the existing container's ID, for kept and removed containers.
+	ID string
+
+	spec *containerSpec
+}
+
+// Plan lists the changes up makes to bring the environment in line with
+// devcontainer.json: the network namespace first, then services, then the
+// dev container, then containers that are no longer configured.
+type Plan struct {
+	Changes []Change
+}
+
+// Count returns the number of changes with the given action.
+func (p *Plan) Count(action string) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Empty reports whether applying the plan would change nothing.
+func (p *Plan) Empty() bool {
+	return p.Count(ActionKeep) == len(p.Changes)
+}
+
+// containerSpec is a container as devcontainer.json describes it.
+type containerSpec struct {
+	role    string
+	opts    runtime.RunOptions
+	volumes []string // named volumes to create first, unprefixed
+	hash    string
+
+	secrets   []string          // secrets written to SecretsDir
+	secretEnv map[string]string // env var -> secret exposed through it
+}
+
+// Plan compares the containers devcontainer.json describes with the ones
+// running for the project. Containers whose spec changed or that are not
+// running are recreated; when the network namespace is recreated, every
+// container joining it is recreated too.
+func (m *Manager) Plan(ctx context.Context) (*Plan, error) {
+	name := m.projectName()
+
+	if err := m.checkResources(ctx); err != nil {
+		return nil, err
+	}
+	specs, err := m.desiredContainers(name)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := m.projectContainers(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+	byName := make(map[string]runtime.Container)
+	for _, c := range existing {
+		byName[c.Name] = c
+	}
+
+	plan := &Plan{}
+	netNSChanged := false
+	for _, spec := range specs {
+		change := Change{Name: spec.opts.Name, Role: spec.role, Action: ActionKeep, spec: spec}
+		c, ok := byName[spec.opts.Name]
+		delete(byName, spec.opts.Name)
+
+		switch {
+		case !ok:
+			change.Action = ActionCreate
+		case c.Labels[configHashLabel] == "":
+			change.Action, change.Reason = ActionRecreate, "created by an older envclone"
+		case c.Labels[configHashLabel] != spec.hash:
+			change.Action, change.Reason = ActionRecreate, "configuration changed"
+		case !c.Running:
+			change.Action, change.Reason = ActionRecreate, "not running"
+		case netNSChanged:
+			change.Action, change.Reason = ActionRecreate, "network namespace is recreated"
+		default:
+			change.ID = c.ID
+		}
+		if ok && change.Action == ActionRecreate {
+			change.ID = c.ID
+		}
+		if spec.role == "netns" && change.Action != ActionKeep {
+			netNSChanged = true
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	var stale []runtime.Container
+	for _, c := range byName {
+		stale = append(stale, c)
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Name < stale[j].Name })
+	for _, c := range stale {
+		plan.Changes = append(plan.Changes, Change{
+			Name:   c.Name,
+			Role:   c.Labels["envclone.role"],
+			Action: ActionRemove,
+			Reason: "no longer in devcontainer.json",
+			ID:     c.ID,
+		})
+	}
+	return plan, nil
+}
+
+// desiredContainers returns the specs of all containers of the
+// environment, in creation order.
+func (m *Manager) desiredContainers(projectName string) ([]*containerSpec, error) {
+	netNSContainer := fmt.Sprintf("envclone-%s-netns", projectName)
+
+	specs := []*containerSpec{newSpec("netns", network.NetNSOptions(projectName, m.Platform.SSHPort()), nil)}
+	for _, svc := range m.Config.Services {
+		specs = append(specs, m.serviceSpec(projectName, netNSContainer, svc))
+	}
+	dev, err := m.devSpec(projectName, netNSContainer)
+	if err != nil {
+		return nil, err
+	}
+	return append(specs, dev), nil
+}
+
+func (m *Manager) devSpec(projectName, netNSContainer string) (*containerSpec, error) {
+	hostPath, containerPath := m.workspacePaths()
+
+	image := m.Config.Image
+	var dockerfile []byte
+	if m.FromSnapshot != "" {
+		image = SnapshotImage(projectName, m.FromSnapshot)
+	} else if m.Config.Build != nil {
+		image = fmt.Sprintf("envclone-%s:latest", projectName)
+		// The image tag stays the same across builds, so the Dockerfile
+		// goes into the hash to recreate the container when it changes.
+		var err error
+		if dockerfile, err = os.ReadFile(m.dockerfilePath()); err != nil {
+			return nil, fmt.Errorf("reading Dockerfile: %w", err)
+		}
+	}
+
+	opts := runtime.RunOptions{
+		Name:    fmt.Sprintf("envclone-%s-dev", projectName),
+		Image:   image,
+		Command: []string{"sleep", "infinity"},
+		Detach:  true,
+		Labels: map[string]string{
+			"envclone.project": projectName,
+			"envclone.role":    "dev",
+		},
+		Network:   fmt.Sprintf("container:%s", netNSContainer),
+		MountArgs: m.Platform.MountArgs(hostPath, containerPath),
+		Workdir:   containerPath,
+		Init:      true,
+		ExtraArgs: m.Config.RunArgs,
+	}
+	m.devLimits(&opts)
+
+	// Apply additional mounts from devcontainer.json, expanding ${localEnv:VAR} references
+	var volumes []string
+	for _, mount := range m.Config.Mounts {
+		vol, name, ok := scopeVolume(projectName, expandLocalEnv(mount))
+		if ok {
+			volumes = append(volumes, name)
+		}
+		opts.Volumes = append(opts.Volumes, vol)
+	}
+
+	secrets, secretEnv, hashed := m.addSecrets(&opts, DevTarget)
+	spec := newSpec("dev", opts, volumes, dockerfile, hashed)
+	spec.secrets, spec.secretEnv = secrets, secretEnv
+	return spec, nil
+}
+
+func (m *Manager) serviceSpec(projectName, netNSContainer string, svc config.ServiceConfig) *containerSpec {
+	opts := runtime.RunOptions{
+		Name:   fmt.Sprintf("envclone-%s-%s", projectName, svc.Name),
+		Image:  svc.Image,
+		Detach: true,
+		Labels: map[string]string{
+			"envclone.project": projectName,
+			"envclone.role":    "service",
+		},
+		Network: fmt.Sprintf("container:%s", netNSContainer),
+		Env:     svc.Env,
+	}
+	serviceLimits(&opts, svc.Resources)
+
+	var volumes []string
+	for _, spec := range svc.Volumes {
+		vol, name, ok := scopeVolume(projectName, spec)
+		if ok {
+			volumes = append(volumes, name)
+		}
+		opts.Volumes = append(opts.Volumes, vol)
+	}
+	secrets, secretEnv, hashed := m.addSecrets(&opts, svc.Name)
+	spec := newSpec("service", opts, volumes, hashed)
+	spec.secrets, spec.secretEnv = secrets, secretEnv
+	return spec
+}
+
+// newSpec hashes opts, plus any extra inputs that affect the container but
+// are not part of its options, and labels the options with the hash.
+func newSpec(role string, opts runtime.RunOptions, volumes []string, extra ...[]byte) *containerSpec {
+	data, _ := json.Marshal(opts)
+	h := sha256.New()
+	h.Write(data)
+	for _, e := range extra {
+		h.Write(e)
+	}
+	hash := fmt.Sprintf("%x", h.Sum(nil))[:12]
+
+	labels := make(map[string]string, len(opts.Labels)+1)
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+	labels[configHashLabel] = hash
+	opts.Labels = labels
+
+	return &containerSpec{role: role, opts: opts, volumes: volumes, hash: hash}
+}
+
+// createContainer creates the named volumes a spec mounts and then the
+// container itself, with its secrets, returning its ID.
+func (m *Manager) createContainer(ctx context.Context, projectName string, spec *containerSpec) (string, error) {
+	for _, name := range spec.volumes {
+		if err := m.ensureVolume(ctx, projectName, name); err != nil {
+			return "", err
+		}
+	}
+	opts, err := m.secretEnv(ctx, spec.opts, spec.secretEnv)
+	if err != nil {
+		return "", err
+	}
+	id, err := m.Runtime.Run(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	if err := m.writeSecrets(ctx, spec.opts.Name, spec.secrets); err != nil {
+		m.Runtime.Remove(context.WithoutCancel(ctx), spec.opts.Name)
+		return "", err
+	}
+	return id, nil
+}
+
+// dockerfilePath resolves build.dockerfile against the .devcontainer
+// directory.
+func (m *Manager) dockerfilePath() string {
+	path := m.Config.Build.Dockerfile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.ProjectDir, ".devcontainer", path)
+	}
+	return path
+}
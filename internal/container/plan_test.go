@@ -0,0 +1,171 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/exec/exectest"
+	"github.com/matoval/envclone/internal/platform"
+	"github.com/matoval/envclone/internal/state"
+)
+
+// runningReplay answers for an environment of project "myapp" in which the
+// network namespace and dev container match testConfig, postgres was
+// created from an older config and a redis service was since removed from
+// it. extra lines are added to the fixture.
+func runningReplay(t *testing.T, mgr *Manager, extra ...string) *exectest.Replay {
+	t.Helper()
+	specs, err := mgr.desiredContainers("myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := make(map[string]string)
+	for _, spec := range specs {
+		hashes[spec.role] = spec.hash
+	}
+
+	container := func(id, name, role, hash string) string {
+		return fmt.Sprintf(`{"Id": %q, "Name": "/envclone-myapp-%s", "State": {"Status": "running", "Running": true}, "Config": {"Labels": {"envclone.project": "myapp", "envclone.role": %q, "envclone.config-hash": %q}}}`,
+			id, name, role, hash)
+	}
+	fixture := strings.Join([]string{
+		"$ * ps -a -q --no-trunc --filter label=envclone.project=myapp",
+		"netns1\npostgres1\nredis1\ndev1",
+		"$ * container inspect netns1 postgres1 redis1 dev1",
+		"[" + strings.Join([]string{
+			container("netns1", "netns", "netns", hashes["netns"]),
+			container("postgres1", "postgres", "service", "0123456789ab"),
+			container("redis1", "redis", "service", "ba9876543210"),
+			container("dev1", "dev", "dev", hashes["dev"]),
+		}, ",\n") + "]",
+		"$ * volume inspect envclone-myapp-pgdata",
+		`[{"Name": "envclone-myapp-pgdata", "Driver": "local"}]`,
+		"$ * run -d --name envclone-myapp-postgres *",
+		"postgres2",
+	}, "\n") + "\n" + strings.Join(extra, "\n")
+
+	replay, err := exectest.ParseReplay(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return replay
+}
+
+func TestPlan(t *testing.T) {
+	v := variants[0]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+	rec.Replay = runningReplay(t, mgr)
+
+	plan, err := mgr.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	var got []string
+	for _, c := range plan.Changes {
+		got = append(got, fmt.Sprintf("%s %s %s", c.Action, c.Name, c.ID))
+	}
+	want := []string{
+		"keep envclone-myapp-netns netns1",
+		"recreate envclone-myapp-postgres postgres1",
+		"keep envclone-myapp-dev dev1",
+		"remove envclone-myapp-redis redis1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("plan:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if plan.Empty() {
+		t.Error("Empty() = true for a plan with changes")
+	}
+}
+
+func TestPlanRecreatesDependentsOfNetNS(t *testing.T) {
+	v := variants[0]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+	rec.Replay = runningReplay(t, mgr)
+	// A different SSH port changes the network namespace.
+	mgr.Platform = &sshPortPlatform{Platform: mgr.Platform, port: 2200}
+
+	plan, err := mgr.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	for _, c := range plan.Changes {
+		if c.Action == ActionKeep {
+			t.Errorf("%s is kept although the network namespace is recreated", c.Name)
+		}
+	}
+}
+
+func TestUpIncremental(t *testing.T) {
+	v := variants[0]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+	rec.Replay = runningReplay(t, mgr)
+
+	env, err := mgr.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if env.NetNSID != "netns1" || env.DevContainerID != "dev1" {
+		t.Errorf("container IDs = %s, %s; want the kept netns1, dev1", env.NetNSID, env.DevContainerID)
+	}
+	if len(env.ServiceIDs) != 1 || env.ServiceIDs[0] != "postgres2" {
+		t.Errorf("ServiceIDs = %v, want [postgres2]", env.ServiceIDs)
+	}
+
+	rec.Golden(t, filepath.Join("testdata", "up_incremental.golden"))
+}
+
+func TestUpIncrementalRollback(t *testing.T) {
+	v := variants[0]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+	rec.Replay = runningReplay(t, mgr,
+		"$ * run -d --name envclone-myapp-dev *",
+		"! pull access denied for example.com/missing")
+	mgr.Previous = &state.Environment{
+		ProjectName:    "myapp",
+		NetNSID:        "netns1",
+		ServiceIDs:     []string{"postgres1", "redis1"},
+		DevContainerID: "dev1",
+		Status:         state.StatusRunning,
+		Containers: []state.ContainerRecord{
+			{Name: "envclone-myapp-netns", Role: "netns", ID: "netns1"},
+			{Name: "envclone-myapp-postgres", Role: "service", ID: "postgres1"},
+			{Name: "envclone-myapp-redis", Role: "service", ID: "redis1"},
+			{Name: "envclone-myapp-dev", Role: "dev", ID: "dev1"},
+		},
+	}
+	// A new image recreates the dev container, which then fails to start.
+	mgr.Config.Image = "example.com/missing"
+
+	env, err := mgr.Up(context.Background())
+	if err == nil {
+		t.Fatal("Up succeeded, want an error")
+	}
+
+	// postgres, redis and dev were removed before the failure; the new
+	// postgres was rolled back. Only the kept netns is left to record.
+	if env == nil || !env.Failed() || env.Error == "" {
+		t.Fatalf("Up returned %+v, want the previous environment marked as failed", env)
+	}
+	if env.NetNSID != "netns1" || env.DevContainerID != "" || len(env.ServiceIDs) != 0 {
+		t.Errorf("container IDs = %s, %s, %v; want only netns1", env.NetNSID, env.DevContainerID, env.ServiceIDs)
+	}
+	if len(env.Containers) != 1 || env.Containers[0].ID != "netns1" {
+		t.Errorf("Containers = %+v, want only the netns record", env.Containers)
+	}
+	if calls := rec.Calls(); calls[len(calls)-1] != "nerdctl rm -f envclone-myapp-postgres" {
+		t.Errorf("last command = %s, want the new postgres rolled back", calls[len(calls)-1])
+	}
+}
+
+// sshPortPlatform overrides the SSH port of a platform.
+type sshPortPlatform struct {
+	platform.Platform
+	port int
+}
+
+func (p *sshPortPlatform) SSHPort() int { return p.port }
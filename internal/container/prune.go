@@ -0,0 +1,185 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matoval/envclone/internal/runtime"
+)
+
+// Kinds of resources Prune removes.
+const (
+	PruneContainer = "container"
+	PruneImage     = "image"
+	PruneVolume    = "volume"
+	PruneState     = "state"
+)
+
+// PruneOptions selects what Prune removes. Containers of projects without
+// a live environment are always removed; images and volumes only on
+// request.
+type PruneOptions struct {
+	Images  bool
+	Volumes bool
+	// OlderThan leaves resources created more recently alone. Resources
+	// whose age is unknown count as old.
+	OlderThan time.Duration
+	// DryRun reports what would be removed without removing anything.
+	DryRun bool
+}
+
+// Pruned is a resource Prune removed, or would remove.
+type Pruned struct {
+	Kind    string
+	Name    string
+	ID      string // of containers and images
+	Project string
+	Size    int64 // bytes; -1 if unknown
+}
+
+// Prune removes the containers, and optionally the images and volumes,
+// that envclone created in rt for projects other than the live ones. It
+// carries on past failures and returns them together with what it
+// removed.
+func Prune(ctx context.Context, rt runtime.Runtime, live []string, opts PruneOptions) ([]Pruned, error) {
+	candidates, err := pruneCandidates(ctx, rt, live, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return candidates, nil
+	}
+
+	// Containers go first, as they hold on to their images and volumes.
+	var pruned []Pruned
+	var errs []error
+	for _, p := range candidates {
+		var err error
+		switch p.Kind {
+		case PruneContainer:
+			err = rt.Remove(ctx, p.Name)
+		case PruneVolume:
+			err = rt.RemoveVolumes(ctx, p.Name)
+		case PruneImage:
+			err = rt.RemoveImage(ctx, p.Name)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("removing %s %s: %w", p.Kind, p.Name, err))
+			continue
+		}
+		pruned = append(pruned, p)
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// pruneCandidates finds the resources Prune removes, in the order they
+// have to be removed in.
+func pruneCandidates(ctx context.Context, rt runtime.Runtime, live []string, opts PruneOptions) ([]Pruned, error) {
+	keep := make(map[string]bool)
+	for _, project := range live {
+		keep[project] = true
+	}
+	cutoff := time.Now().Add(-opts.OlderThan)
+	old := func(created time.Time) bool {
+		return opts.OlderThan == 0 || created.IsZero() || created.Before(cutoff)
+	}
+
+	var candidates []Pruned
+	containers, err := rt.List(ctx, "label=envclone.project")
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	for _, c := range containers {
+		if project := c.Labels["envclone.project"]; !keep[project] && old(c.Created) {
+			candidates = append(candidates, Pruned{Kind: PruneContainer, Name: c.Name, ID: c.ID, Project: project, Size: -1})
+		}
+	}
+
+	if opts.Volumes {
+		volumes, err := rt.ListVolumes(ctx, "label=envclone.project")
+		if err != nil {
+			return nil, fmt.Errorf("listing volumes: %w", err)
+		}
+		sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+		for _, v := range volumes {
+			if project := v.Labels["envclone.project"]; !keep[project] && old(v.Created) {
+				size := int64(-1)
+				if !opts.DryRun {
+					size = volumeSize(ctx, rt, v.Name)
+				}
+				candidates = append(candidates, Pruned{Kind: PruneVolume, Name: v.Name, Project: project, Size: size})
+			}
+		}
+	}
+
+	if opts.Images {
+		images, err := rt.ListImages(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing images: %w", err)
+		}
+		var found []Pruned
+		for _, img := range images {
+			// Space is freed once, when the last tag goes.
+			size := img.Size
+			for _, tag := range img.RepoTags {
+				if project, ok := ImageProject(tag); ok && !keep[project] && old(img.Created) {
+					found = append(found, Pruned{Kind: PruneImage, Name: tag, ID: img.ID, Project: project, Size: size})
+					size = 0
+				}
+			}
+		}
+		sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+		candidates = append(candidates, found...)
+	}
+	return candidates, nil
+}
+
+// ImageProject returns the project of an image envclone built or
+// committed: envclone-<project>:latest or envclone-<project>-snapshot:<tag>.
+// A registry prefix, such as podman's localhost/, is ignored.
+func ImageProject(ref string) (string, bool) {
+	if i := strings.LastIndex(ref, "/"); i != -1 {
+		ref = ref[i+1:]
+	}
+	repo, tag, _ := strings.Cut(ref, ":")
+	project, ok := strings.CutPrefix(repo, "envclone-")
+	if !ok || project == "" {
+		return "", false
+	}
+	if p, ok := strings.CutSuffix(project, "-snapshot"); ok {
+		return p, true
+	}
+	if tag != "latest" {
+		return "", false
+	}
+	return project, true
+}
+
+// volumeSize measures a volume with a throwaway helper container. It
+// returns -1 if the size cannot be measured.
+func volumeSize(ctx context.Context, rt runtime.Runtime, volume string) int64 {
+	out, err := rt.Run(ctx, runtime.RunOptions{
+		Image:   helperImage,
+		Command: []string{"du", "-sk", "/data"},
+		Remove:  true,
+		Volumes: []string{volume + ":/data:ro"},
+	})
+	if err != nil {
+		return -1
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return -1
+	}
+	kb, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return kb * 1024
+}
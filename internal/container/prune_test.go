@@ -0,0 +1,112 @@
+package container
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matoval/envclone/internal/exec/exectest"
+	"github.com/matoval/envclone/internal/runtime"
+)
+
+const pruneFixture = `
+$ * ps -a -q --no-trunc --filter label=envclone.project
+dev1
+old1
+$ * container inspect dev1 old1
+[{"Id": "dev1", "Name": "/envclone-myapp-dev", "Created": "2026-10-18T08:00:00Z", "Config": {"Labels": {"envclone.project": "myapp"}}},
+ {"Id": "old1", "Name": "/envclone-old-dev", "Created": "2026-01-01T08:00:00Z", "Config": {"Labels": {"envclone.project": "old"}}}]
+$ * volume ls -q --filter label=envclone.project
+envclone-myapp-pgdata
+envclone-old-pgdata
+$ * volume inspect envclone-myapp-pgdata envclone-old-pgdata
+[{"Name": "envclone-myapp-pgdata", "Labels": {"envclone.project": "myapp"}},
+ {"Name": "envclone-old-pgdata", "Labels": {"envclone.project": "old"}}]
+$ * run --rm -v envclone-old-pgdata:/data:ro * du -sk /data
+2048	/data
+$ * image ls -q --no-trunc
+sha256:aaa
+sha256:bbb
+sha256:ccc
+$ * image inspect sha256:aaa sha256:bbb sha256:ccc
+[{"Id": "sha256:aaa", "RepoTags": ["envclone-myapp:latest"], "Size": 1000},
+ {"Id": "sha256:bbb", "RepoTags": ["localhost/envclone-old:latest", "envclone-old-snapshot:v1"], "Size": 2000},
+ {"Id": "sha256:ccc", "RepoTags": ["postgres:16"], "Size": 3000}]
+`
+
+func TestPrune(t *testing.T) {
+	replay, err := exectest.ParseReplay(strings.NewReader(pruneFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &exectest.Recorder{Replay: replay}
+	rt := runtime.NewDocker(rec)
+
+	pruned, err := Prune(context.Background(), rt, []string{"myapp"}, PruneOptions{Images: true, Volumes: true})
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	var got []string
+	for _, p := range pruned {
+		got = append(got, strings.Join([]string{p.Kind, p.Name, p.Project}, " "))
+	}
+	want := []string{
+		"container envclone-old-dev old",
+		"volume envclone-old-pgdata old",
+		"image envclone-old-snapshot:v1 old",
+		"image localhost/envclone-old:latest old",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("pruned:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if pruned[1].Size != 2048*1024 || pruned[2].Size+pruned[3].Size != 2000 {
+		t.Errorf("sizes = %d, %d+%d; want the volume measured and the image counted once", pruned[1].Size, pruned[2].Size, pruned[3].Size)
+	}
+
+	commands := strings.Join(rec.Calls(), "\n")
+	for _, cmd := range []string{"rm -f envclone-old-dev", "volume rm envclone-old-pgdata", "rmi envclone-old-snapshot:v1"} {
+		if !strings.Contains(commands, cmd) {
+			t.Errorf("no %q in commands:\n%s", cmd, commands)
+		}
+	}
+	if strings.Contains(commands, "rm -f envclone-myapp") || strings.Contains(commands, "rm envclone-myapp") {
+		t.Errorf("live project pruned:\n%s", commands)
+	}
+}
+
+func TestPruneOlderThan(t *testing.T) {
+	replay, err := exectest.ParseReplay(strings.NewReader(pruneFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := runtime.NewDocker(&exectest.Recorder{Replay: replay})
+
+	// Only the January container is older than a month; the volume's age
+	// is unknown, so it counts as old.
+	pruned, err := Prune(context.Background(), rt, nil, PruneOptions{Volumes: true, OlderThan: 30 * 24 * time.Hour, DryRun: true})
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	var got []string
+	for _, p := range pruned {
+		got = append(got, p.Name)
+	}
+	if want := "envclone-old-dev envclone-myapp-pgdata envclone-old-pgdata"; strings.Join(got, " ") != want {
+		t.Errorf("pruned = %v, want %s", got, want)
+	}
+}
+
+func TestImageProject(t *testing.T) {
+	for ref, want := range map[string]string{
+		"envclone-myapp:latest":             "myapp",
+		"localhost/envclone-my-app:latest":  "my-app",
+		"envclone-myapp-snapshot:before-ui": "myapp",
+		"envclone-myapp:v2":                 "",
+		"postgres:16":                       "",
+	} {
+		if got, _ := ImageProject(ref); got != want {
+			t.Errorf("ImageProject(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
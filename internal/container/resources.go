@@ -0,0 +1,136 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"syscall"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/runtime"
+)
+
+// hostInfo returns the capacity of the container host, asking the runtime
+// once. It is nil when devcontainer.json sets no limits or requirements,
+// or when the runtime does not report it.
+func (m *Manager) hostInfo(ctx context.Context) *runtime.Info {
+	if m.hostChecked || !hasResources(m.Config) {
+		return m.host
+	}
+	m.hostChecked = true
+	info, err := m.Runtime.Info(ctx)
+	if err != nil {
+		fmt.Printf("Warning: not checking resource limits, the host's capacity is unknown: %v\n", err)
+		return nil
+	}
+	m.host = info
+	return info
+}
+
+func hasResources(cfg *config.DevContainer) bool {
+	if cfg.HostRequirements != nil {
+		return true
+	}
+	for _, svc := range cfg.Services {
+		if svc.Resources != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkResources compares devcontainer.json with the host's capacity. A
+// host short of hostRequirements only gets a warning, since the dev
+// container's limits are capped at what the host has; service limits
+// beyond it are an error, as the runtime would refuse them.
+func (m *Manager) checkResources(ctx context.Context) error {
+	info := m.hostInfo(ctx)
+	if info == nil {
+		return nil
+	}
+
+	if req := m.Config.HostRequirements; req != nil {
+		if info.CPUs > 0 && req.CPUs > info.CPUs {
+			fmt.Printf("Warning: hostRequirements asks for %d CPUs, but the container host has %d\n", req.CPUs, info.CPUs)
+		}
+		if mem, _ := config.ParseSize(req.Memory); info.Memory > 0 && mem > info.Memory {
+			fmt.Printf("Warning: hostRequirements asks for %s of memory, but the container host has %s\n", req.Memory, formatBytes(info.Memory))
+		}
+		if storage, _ := config.ParseSize(req.Storage); storage > 0 {
+			if free, ok := freeSpace(info.StorageDir); ok && storage > free {
+				fmt.Printf("Warning: hostRequirements asks for %s of storage, but %s has %s free\n", req.Storage, info.StorageDir, formatBytes(free))
+			}
+		}
+	}
+
+	for _, svc := range m.Config.Services {
+		res := svc.Resources
+		if res == nil {
+			continue
+		}
+		if info.CPUs > 0 && res.CPUs > float64(info.CPUs) {
+			return fmt.Errorf("service %q: resources.cpus is %g, but the container host has %d CPUs", svc.Name, res.CPUs, info.CPUs)
+		}
+		if mem, _ := config.ParseSize(res.Memory); info.Memory > 0 && mem > info.Memory {
+			return fmt.Errorf("service %q: resources.memory is %s, but the container host has %s", svc.Name, res.Memory, formatBytes(info.Memory))
+		}
+	}
+	return nil
+}
+
+// devLimits sets the dev container's CPU and memory limits from
+// hostRequirements, capped at the host's capacity when it is known.
+// Storage is not limited; checkResources only warns when it is short.
+func (m *Manager) devLimits(opts *runtime.RunOptions) {
+	req := m.Config.HostRequirements
+	if req == nil {
+		return
+	}
+	opts.CPUs = float64(req.CPUs)
+	opts.Memory, _ = config.ParseSize(req.Memory)
+	if m.host != nil {
+		if m.host.CPUs > 0 {
+			opts.CPUs = min(opts.CPUs, float64(m.host.CPUs))
+		}
+		if m.host.Memory > 0 {
+			opts.Memory = min(opts.Memory, m.host.Memory)
+		}
+	}
+}
+
+// serviceLimits sets a service container's limits from its resources.
+func serviceLimits(opts *runtime.RunOptions, res *config.Resources) {
+	if res == nil {
+		return
+	}
+	opts.CPUs = res.CPUs
+	opts.Memory, _ = config.ParseSize(res.Memory)
+	opts.PidsLimit = res.PIDs
+}
+
+// freeSpace returns the space available in dir, if dir is on this
+// machine. Runtimes in a VM report a directory inside it.
+func freeSpace(dir string) (int64, bool) {
+	if dir == "" {
+		return 0, false
+	}
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return 0, false
+	}
+	return int64(fs.Bavail) * int64(fs.Bsize), true
+}
+
+// formatBytes renders a size in the binary units devcontainer.json uses,
+// e.g. "15.6gb".
+func formatBytes(n int64) string {
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}} {
+		if n >= u.size {
+			return strconv.FormatFloat(float64(n)/float64(u.size), 'f', 1, 64) + u.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10) + "b"
+}
@@ -0,0 +1,76 @@
+package container
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/exec/exectest"
+)
+
+func TestResourceLimits(t *testing.T) {
+	v := variants[2] // docker
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+	replay, err := exectest.ParseReplay(strings.NewReader(`$ * info --format *
+{"NCPU": 4, "MemTotal": 8589934592}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Replay = replay
+
+	// The dev container asks for more CPUs than the host has and is capped.
+	mgr.Config.HostRequirements = &config.HostRequirements{CPUs: 8, Memory: "4gb"}
+	mgr.Config.Services[0].Resources = &config.Resources{CPUs: 0.5, Memory: "512mb", PIDs: 100}
+
+	plan, err := mgr.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	for _, c := range plan.Changes {
+		opts := c.spec.opts
+		switch c.Role {
+		case "dev":
+			if opts.CPUs != 4 || opts.Memory != 4<<30 || opts.PidsLimit != 0 {
+				t.Errorf("dev limits = %g CPUs, %d bytes, %d PIDs; want 4, 4gb, none", opts.CPUs, opts.Memory, opts.PidsLimit)
+			}
+		case "service":
+			if opts.CPUs != 0.5 || opts.Memory != 512<<20 || opts.PidsLimit != 100 {
+				t.Errorf("postgres limits = %g CPUs, %d bytes, %d PIDs; want 0.5, 512mb, 100", opts.CPUs, opts.Memory, opts.PidsLimit)
+			}
+		}
+	}
+
+	// Services cannot be given more than the host has.
+	mgr.Config.Services[0].Resources.Memory = "16gb"
+	if _, err := mgr.Plan(context.Background()); err == nil || !strings.Contains(err.Error(), "resources.memory") {
+		t.Errorf("Plan with a service over the host's memory: err = %v", err)
+	}
+
+	// The host is asked once.
+	n := 0
+	for _, call := range rec.Calls() {
+		if strings.Contains(call, " info ") {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("info queried %d times, want 1", n)
+	}
+}
+
+func TestRunLimitFlags(t *testing.T) {
+	v := variants[2]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+	mgr.Config.Services[0].Resources = &config.Resources{CPUs: 1.5, Memory: "1gb", PIDs: 64}
+
+	spec := mgr.serviceSpec("myapp", "envclone-myapp-netns", mgr.Config.Services[0])
+	if _, err := mgr.Runtime.Run(context.Background(), spec.opts); err != nil {
+		t.Fatal(err)
+	}
+	calls := rec.Calls()
+	if len(calls) != 1 || !strings.Contains(calls[0], " --cpus 1.5 --memory 1073741824 --pids-limit 64 ") {
+		t.Errorf("run = %q, want the limit flags", calls)
+	}
+}
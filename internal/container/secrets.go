@@ -0,0 +1,178 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/redact"
+	"github.com/matoval/envclone/internal/runtime"
+)
+
+// SecretsDir is where secrets are mounted in containers. It is a tmpfs, so
+// secrets never reach the container's filesystem or a snapshot of it.
+const SecretsDir = "/run/secrets"
+
+// secretNames returns the secrets a container gets, sorted: all of them
+// for the dev container, the ones listing it for a service.
+func (m *Manager) secretNames(target string) []string {
+	var names []string
+	for name, secret := range m.Config.Secrets {
+		if target == DevTarget || contains(secret.Services, target) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addSecrets mounts the tmpfs for a container's secrets and records which
+// secrets it gets in spec. The values are added only when the container
+// is created, so they stay out of the config hash.
+func (m *Manager) addSecrets(opts *runtime.RunOptions, target string) (names []string, env map[string]string, hashed []byte) {
+	names = m.secretNames(target)
+	if len(names) == 0 {
+		return nil, nil, nil
+	}
+	opts.Tmpfs = append(opts.Tmpfs, SecretsDir+":mode=0755")
+
+	// Names and variables go into the hash so adding or removing a secret
+	// recreates the container.
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "secret %s\n", name)
+		if v := m.Config.Secrets[name].EnvVar; v != "" {
+			if env == nil {
+				env = make(map[string]string)
+			}
+			env[v] = name
+			fmt.Fprintf(&b, "env %s=%s\n", v, name)
+		}
+	}
+	return names, env, []byte(b.String())
+}
+
+// secretValues reads all configured secrets on the host, once per Manager,
+// and registers them for redaction.
+func (m *Manager) secretValues(ctx context.Context) (map[string]string, error) {
+	if m.secrets != nil {
+		return m.secrets, nil
+	}
+	values := make(map[string]string, len(m.Config.Secrets))
+	for name, secret := range m.Config.Secrets {
+		value, err := readSecret(ctx, secret)
+		if err != nil {
+			return nil, fmt.Errorf("reading secret %q: %w", name, err)
+		}
+		redact.AddSecrets(value)
+		values[name] = value
+	}
+	m.secrets = values
+	return values, nil
+}
+
+// readSecret reads a secret from its source. A trailing newline, as
+// printed by most commands and left by editors, is dropped.
+func readSecret(ctx context.Context, secret config.SecretConfig) (string, error) {
+	var value string
+	switch {
+	case secret.Env != "":
+		v, ok := os.LookupEnv(secret.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", secret.Env)
+		}
+		value = v
+	case secret.File != "":
+		data, err := os.ReadFile(expandHome(expandLocalEnv(secret.File)))
+		if err != nil {
+			return "", err
+		}
+		value = string(data)
+	default:
+		// Run directly rather than through the Runner, which would log
+		// the output before it is registered for redaction.
+		cmd := exec.CommandContext(ctx, "sh", "-c", secret.Command)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w\n%s", secret.Command, err, stderr.String())
+		}
+		value = string(out)
+	}
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "\n"), "\r")
+	if value == "" {
+		return "", fmt.Errorf("secret is empty")
+	}
+	return value, nil
+}
+
+// secretEnv returns opts with the env vars of exposed secrets added.
+func (m *Manager) secretEnv(ctx context.Context, opts runtime.RunOptions, env map[string]string) (runtime.RunOptions, error) {
+	if len(env) == 0 {
+		return opts, nil
+	}
+	values, err := m.secretValues(ctx)
+	if err != nil {
+		return opts, err
+	}
+	vars := make([]string, 0, len(env))
+	for v := range env {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+
+	opts.Env = append([]string(nil), opts.Env...)
+	for _, v := range vars {
+		opts.Env = append(opts.Env, v+"="+values[env[v]])
+	}
+	return opts, nil
+}
+
+// writeSecrets writes secrets into a running container's tmpfs. It runs
+// whenever a container is created or started, as the tmpfs starts out
+// empty, and on every up, so changed secrets are picked up.
+func (m *Manager) writeSecrets(ctx context.Context, containerName string, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	values, err := m.secretValues(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		// Values go through stdin, never on a command line.
+		opts := runtime.ExecOptions{User: "0", Stdin: strings.NewReader(values[name])}
+		script := `cat > "$1" && chmod 0444 "$1"`
+		if _, err := m.Runtime.Exec(ctx, containerName, opts, "sh", "-c", script, "sh", path.Join(SecretsDir, name)); err != nil {
+			return fmt.Errorf("writing secret %q to %s: %w", name, containerName, err)
+		}
+	}
+	return nil
+}
+
+// expandHome replaces a leading ~ with the user's home directory.
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
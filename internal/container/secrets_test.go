@@ -0,0 +1,61 @@
+package container
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/redact"
+)
+
+func TestUpWithSecrets(t *testing.T) {
+	t.Cleanup(redact.Reset)
+	v := variants[0]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+
+	t.Setenv("ENVCLONE_TEST_TOKEN", "tok-from-env")
+	keyFile := filepath.Join(t.TempDir(), "db")
+	if err := os.WriteFile(keyFile, []byte("pw-from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mgr.Config.Secrets = map[string]config.SecretConfig{
+		"github_token": {Env: "ENVCLONE_TEST_TOKEN"},
+		"db_password":  {File: keyFile, Services: []string{"postgres"}, EnvVar: "DB_PASSWORD"},
+		"api_key":      {Command: "echo key-from-command"},
+	}
+
+	if _, err := mgr.Up(context.Background()); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	rec.Golden(t, filepath.Join("testdata", "up_secrets.golden"))
+
+	// Only the secret exposed as an env var may appear on a command line.
+	for _, call := range rec.Calls() {
+		if strings.Contains(call, "tok-from-env") || strings.Contains(call, "key-from-command") {
+			t.Errorf("secret value on a command line: %s", call)
+		}
+	}
+	if got := redact.String("DB_PASSWORD=pw-from-file key-from-command"); strings.Contains(got, "from-") {
+		t.Errorf("secrets not registered for redaction: %s", got)
+	}
+}
+
+func TestReadSecretErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret config.SecretConfig
+	}{
+		{"unset env", config.SecretConfig{Env: "ENVCLONE_TEST_UNSET"}},
+		{"missing file", config.SecretConfig{File: filepath.Join(t.TempDir(), "missing")}},
+		{"failing command", config.SecretConfig{Command: "exit 3"}},
+		{"empty", config.SecretConfig{Command: "echo"}},
+	}
+	for _, tt := range tests {
+		if _, err := readSecret(context.Background(), tt.secret); err == nil {
+			t.Errorf("%s: readSecret succeeded, want an error", tt.name)
+		}
+	}
+}
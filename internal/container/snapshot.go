@@ -0,0 +1,47 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/matoval/envclone/internal/state"
+)
+
+// SnapshotImage returns the image reference a dev container snapshot is
+// committed to. The repository name scopes snapshots to the project.
+func SnapshotImage(projectName, tag string) string {
+	return fmt.Sprintf("envclone-%s-snapshot:%s", projectName, tag)
+}
+
+// Snapshot commits the running dev container to a local image labelled with
+// the project and tag. The returned record carries the fingerprint of the
+// config the environment was started from, so later ups can warn when the
+// config has drifted since.
+func (m *Manager) Snapshot(ctx context.Context, env *state.Environment, tag string) (state.Snapshot, error) {
+	devContainer := fmt.Sprintf("envclone-%s-dev", env.ProjectName)
+	image := SnapshotImage(env.ProjectName, tag)
+
+	labels := map[string]string{
+		"envclone.project":  env.ProjectName,
+		"envclone.snapshot": tag,
+	}
+	if err := m.Runtime.Commit(ctx, devContainer, image, labels); err != nil {
+		return state.Snapshot{}, fmt.Errorf("committing dev container: %w", err)
+	}
+
+	return state.Snapshot{
+		Tag:        tag,
+		Image:      image,
+		ConfigHash: env.ConfigHash,
+		Created:    time.Now(),
+	}, nil
+}
+
+// RemoveSnapshot deletes a snapshot image.
+func (m *Manager) RemoveSnapshot(ctx context.Context, tag string) error {
+	if err := m.Runtime.RemoveImage(ctx, SnapshotImage(m.projectName(), tag)); err != nil {
+		return fmt.Errorf("removing snapshot %s: %w", tag, err)
+	}
+	return nil
+}
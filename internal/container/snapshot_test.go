@@ -0,0 +1,50 @@
+package container
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/state"
+)
+
+func TestSnapshot(t *testing.T) {
+	v := variants[2]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+	env := &state.Environment{ProjectName: "myapp", ConfigHash: "started-from"}
+
+	snap, err := mgr.Snapshot(context.Background(), env, "v1")
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if snap.Image != "envclone-myapp-snapshot:v1" || snap.ConfigHash != "started-from" {
+		t.Errorf("snapshot = %+v, want image envclone-myapp-snapshot:v1 and the environment's config hash", snap)
+	}
+	calls := rec.Calls()
+	want := `docker commit --change "LABEL envclone.project=myapp" --change "LABEL envclone.snapshot=v1" envclone-myapp-dev envclone-myapp-snapshot:v1`
+	if len(calls) != 1 || calls[0] != want {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestUpFromSnapshot(t *testing.T) {
+	v := variants[2]
+	mgr, rec := newTestManager(t, v.platform, v.newRuntime, filepath.Join("testdata", "up.replay"))
+	mgr.FromSnapshot = "v1"
+
+	env, err := mgr.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if env.Snapshot != "v1" {
+		t.Errorf("Snapshot = %q, want v1 recorded for later ups", env.Snapshot)
+	}
+	found := false
+	for _, call := range rec.Calls() {
+		found = found || strings.Contains(call, " envclone-myapp-snapshot:v1 sleep infinity")
+	}
+	if !found {
+		t.Errorf("dev container not run from the snapshot: %q", rec.Calls())
+	}
+}
@@ -0,0 +1,72 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+// ContainerStats is the resource usage of a container of the environment.
+type ContainerStats struct {
+	Target  string // service name or "dev"
+	Running bool
+	runtime.Stats
+}
+
+// Stats returns the resource usage of the services and the dev container,
+// in that order. Stopped containers are included with no usage.
+func (m *Manager) Stats(ctx context.Context, env *state.Environment) ([]ContainerStats, error) {
+	infos, err := m.Status(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+
+	prefix := fmt.Sprintf("envclone-%s-", env.ProjectName)
+	var services, dev []ContainerStats
+	var running []string
+	for _, info := range infos {
+		s := ContainerStats{Target: strings.TrimPrefix(info.Name, prefix), Running: info.Running}
+		s.ID, s.Name = info.ID, info.Name
+		switch info.Role {
+		case "service":
+			services = append(services, s)
+		case "dev":
+			dev = append(dev, s)
+		default:
+			continue
+		}
+		if info.Running {
+			running = append(running, info.Name)
+		}
+	}
+
+	usage, err := m.Runtime.Stats(ctx, running...)
+	if err != nil {
+		return nil, fmt.Errorf("reading stats: %w", err)
+	}
+	stats := append(services, dev...)
+	for i := range stats {
+		for _, u := range usage {
+			if u.Name == stats[i].Name || (u.ID != "" && strings.HasPrefix(stats[i].ID, u.ID)) {
+				// Keep the full ID and name; runtimes shorten them.
+				u.ID, u.Name = stats[i].ID, stats[i].Name
+				stats[i].Stats = u
+				break
+			}
+		}
+	}
+	return stats, nil
+}
+
+// Top lists the processes running in the target container: a service
+// name, or "" or "dev" for the dev container.
+func (m *Manager) Top(ctx context.Context, env *state.Environment, target string) (*runtime.Processes, error) {
+	name, err := m.targetContainer(ctx, env, target)
+	if err != nil {
+		return nil, err
+	}
+	return m.Runtime.Top(ctx, name)
+}
@@ -0,0 +1,71 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/exec/exectest"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+const statsFixture = `
+$ * ps -a -q --no-trunc --filter label=envclone.project=myapp
+netns1
+dev1
+postgres1
+redis1
+$ * container inspect netns1 dev1 postgres1 redis1
+[{"Id": "netns1", "Name": "/envclone-myapp-netns", "State": {"Status": "running", "Running": true}, "Config": {"Labels": {"envclone.project": "myapp", "envclone.role": "netns"}}},
+ {"Id": "dev1", "Name": "/envclone-myapp-dev", "State": {"Status": "running", "Running": true}, "Config": {"Labels": {"envclone.project": "myapp", "envclone.role": "dev"}}},
+ {"Id": "postgres1", "Name": "/envclone-myapp-postgres", "State": {"Status": "running", "Running": true}, "Config": {"Labels": {"envclone.project": "myapp", "envclone.role": "service"}}},
+ {"Id": "redis1", "Name": "/envclone-myapp-redis", "State": {"Status": "exited"}, "Config": {"Labels": {"envclone.project": "myapp", "envclone.role": "service"}}}]
+$ * stats --no-stream --format * envclone-myapp-dev envclone-myapp-postgres
+{"ID": "dev1", "Name": "envclone-myapp-dev", "CPUPerc": "12.00%", "MemUsage": "100MiB / 8GiB", "NetIO": "2kB / 1kB", "BlockIO": "0B / 4kB", "PIDs": "9"}
+{"ID": "postgres1", "Name": "envclone-myapp-postgres", "CPUPerc": "0.50%", "MemUsage": "30MiB / 512MiB", "NetIO": "2kB / 1kB", "BlockIO": "1MB / 0B", "PIDs": "6"}
+$ * container inspect envclone-myapp-dev
+[{"Id": "dev1", "Name": "/envclone-myapp-dev", "State": {"Status": "running", "Running": true}}]
+$ * top envclone-myapp-dev
+UID   PID   PPID  CMD
+root  100   90    sleep infinity
+`
+
+func TestStatsAndTop(t *testing.T) {
+	replay, err := exectest.ParseReplay(strings.NewReader(statsFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &exectest.Recorder{Replay: replay}
+	mgr := &Manager{Runtime: runtime.NewDocker(rec), ProjectDir: "/src/myapp"}
+	env := &state.Environment{ProjectName: "myapp", ProjectDir: "/src/myapp"}
+
+	stats, err := mgr.Stats(context.Background(), env)
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	var got []string
+	for _, s := range stats {
+		got = append(got, fmt.Sprintf("%s %t %.1f %d %d %d %d", s.Target, s.Running, s.CPUPercent, s.MemoryUsage, s.NetRx, s.BlockRead, s.PIDs))
+	}
+	want := []string{
+		"postgres true 0.5 31457280 2000 1000000 6",
+		"redis false 0.0 0 0 0 0",
+		"dev true 12.0 104857600 2000 0 9",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("stats:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	procs, err := mgr.Top(context.Background(), env, "")
+	if err != nil {
+		t.Fatalf("Top: %v", err)
+	}
+	if len(procs.Rows) != 1 || procs.Rows[0][3] != "sleep infinity" {
+		t.Errorf("Top rows = %q, want the sleep process", procs.Rows)
+	}
+	if _, err := mgr.Top(context.Background(), env, "mysql"); err == nil || !strings.Contains(err.Error(), "available: postgres, redis, dev") {
+		t.Errorf("Top of an unknown service: err = %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+// Environment states in a Summary.
+const (
+	SummaryRunning  = "running"
+	SummaryDegraded = "degraded" // some containers are missing or not running
+	SummaryStopped  = "stopped"
+	SummaryFailed   = "failed"   // the last up did not complete
+	SummaryOrphaned = "orphaned" // labelled containers without state
+)
+
+// Config states in a Summary, comparing devcontainer.json with the config
+// the environment was last brought up with.
+const (
+	ConfigCurrent = "current"
+	ConfigChanged = "changed"
+	ConfigMissing = "missing" // devcontainer.json or the project is gone
+	ConfigInvalid = "invalid"
+	ConfigUnknown = "unknown" // not recorded
+)
+
+// Summary is an overview of one environment, as shown by 'envclone ls'.
+type Summary struct {
+	Project    string    `json:"project"`
+	ProjectDir string    `json:"projectDir,omitempty"`
+	Runtime    string    `json:"runtime"`
+	Status     string    `json:"status"`
+	Config     string    `json:"config"`
+	StartedAt  time.Time `json:"startedAt,omitzero"` // of the running network namespace
+	Ports      []string  `json:"ports,omitempty"`
+	Containers int       `json:"containers"`
+	Running    int       `json:"running"`
+	Usage      *Usage    `json:"usage,omitempty"` // nil if unavailable
+}
+
+// Usage is the combined resource usage of an environment's running
+// containers.
+type Usage struct {
+	CPUPercent  float64 `json:"cpuPercent"`
+	MemoryBytes uint64  `json:"memoryBytes"`
+}
+
+// Summarize summarizes the environments recorded for rt, followed by
+// projects that have containers in rt but no state.
+func Summarize(ctx context.Context, rt runtime.Runtime, envs []*state.Environment) ([]Summary, error) {
+	containers, err := rt.List(ctx, "label=envclone.project")
+	if err != nil {
+		return nil, err
+	}
+	byProject := make(map[string][]runtime.Container)
+	for _, c := range containers {
+		project := c.Labels["envclone.project"]
+		byProject[project] = append(byProject[project], c)
+	}
+
+	var summaries []Summary
+	known := make(map[string]bool)
+	for _, env := range envs {
+		known[env.ProjectName] = true
+		s := summarize(env.ProjectName, rt.Name(), byProject[env.ProjectName])
+		s.ProjectDir = env.ProjectDir
+		s.Ports = env.Ports
+		s.Config = configStatus(env)
+		switch {
+		case env.Failed():
+			s.Status = SummaryFailed
+		case s.Running == 0:
+			s.Status = SummaryStopped
+		case s.Running < expectedRunning(env):
+			s.Status = SummaryDegraded
+		}
+		summaries = append(summaries, s)
+	}
+
+	var orphans []string
+	for project := range byProject {
+		if !known[project] {
+			orphans = append(orphans, project)
+		}
+	}
+	sort.Strings(orphans)
+	for _, project := range orphans {
+		s := summarize(project, rt.Name(), byProject[project])
+		s.Status = SummaryOrphaned
+		s.Config = ConfigUnknown
+		summaries = append(summaries, s)
+	}
+
+	addUsage(ctx, rt, summaries, byProject)
+	return summaries, nil
+}
+
+// summarize counts a project's containers and finds when its network
+// namespace started. The status is running until the caller says otherwise.
+func summarize(project, runtimeName string, containers []runtime.Container) Summary {
+	s := Summary{Project: project, Runtime: runtimeName, Status: SummaryRunning, Containers: len(containers)}
+	for _, c := range containers {
+		if !c.Running {
+			continue
+		}
+		s.Running++
+		if c.Labels["envclone.role"] == "netns" {
+			s.StartedAt = c.StartedAt
+		}
+	}
+	return s
+}
+
+// expectedRunning returns how many containers of env should be running:
+// all recorded ones except those stopped with 'envclone stop'.
+func expectedRunning(env *state.Environment) int {
+	n := 0
+	for _, rec := range recordedContainers(env) {
+		if !rec.Stopped {
+			n++
+		}
+	}
+	return n
+}
+
+func configStatus(env *state.Environment) string {
+	cfg, err := config.Load(env.ProjectDir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return ConfigMissing
+	case err != nil:
+		return ConfigInvalid
+	case env.ConfigHash == "":
+		return ConfigUnknown
+	case cfg.Fingerprint() != env.ConfigHash:
+		return ConfigChanged
+	}
+	return ConfigCurrent
+}
+
+// addUsage adds up the resource usage of each summary's running
+// containers. Usage is left out if the runtime cannot report it.
+func addUsage(ctx context.Context, rt runtime.Runtime, summaries []Summary, byProject map[string][]runtime.Container) {
+	var names []string
+	project := make(map[string]string)
+	var running []runtime.Container
+	for _, containers := range byProject {
+		for _, c := range containers {
+			if c.Running {
+				names = append(names, c.Name)
+				project[c.Name] = c.Labels["envclone.project"]
+				running = append(running, c)
+			}
+		}
+	}
+	sort.Strings(names)
+	stats, err := rt.Stats(ctx, names...)
+	if err != nil {
+		return
+	}
+
+	usage := make(map[string]*Usage)
+	for _, st := range stats {
+		p, ok := project[st.Name]
+		if !ok {
+			// Some runtimes only report a short ID.
+			for _, c := range running {
+				if st.ID != "" && strings.HasPrefix(c.ID, st.ID) {
+					p = c.Labels["envclone.project"]
+				}
+			}
+		}
+		if usage[p] == nil {
+			usage[p] = &Usage{}
+		}
+		usage[p].CPUPercent += st.CPUPercent
+		usage[p].MemoryBytes += st.MemoryUsage
+	}
+	for i := range summaries {
+		summaries[i].Usage = usage[summaries[i].Project]
+	}
+}
@@ -0,0 +1,80 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/exec/exectest"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+const summaryFixture = `
+$ * ps -a -q --no-trunc --filter label=envclone.project
+netns1
+dev1
+netns2
+old1
+$ * container inspect netns1 dev1 netns2 old1
+[{"Id": "netns1", "Name": "/envclone-myapp-netns", "State": {"Status": "running", "Running": true, "StartedAt": "2026-10-19T08:00:00Z"}, "Config": {"Labels": {"envclone.project": "myapp", "envclone.role": "netns"}}},
+ {"Id": "dev1", "Name": "/envclone-myapp-dev", "State": {"Status": "exited"}, "Config": {"Labels": {"envclone.project": "myapp", "envclone.role": "dev"}}},
+ {"Id": "netns2", "Name": "/envclone-web-netns", "State": {"Status": "exited"}, "Config": {"Labels": {"envclone.project": "web", "envclone.role": "netns"}}},
+ {"Id": "old1", "Name": "/envclone-old-dev", "State": {"Status": "running", "Running": true}, "Config": {"Labels": {"envclone.project": "old", "envclone.role": "dev"}}}]
+$ * stats --no-stream --format * envclone-myapp-netns envclone-old-dev
+{"ID": "netns1", "Name": "envclone-myapp-netns", "CPUPerc": "0.50%", "MemUsage": "1MiB / 8GiB", "PIDs": "1"}
+{"ID": "old1", "Name": "envclone-old-dev", "CPUPerc": "2.00%", "MemUsage": "100MiB / 8GiB", "PIDs": "4"}
+`
+
+func TestSummarize(t *testing.T) {
+	replay, err := exectest.ParseReplay(strings.NewReader(summaryFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := runtime.NewDocker(&exectest.Recorder{Replay: replay})
+
+	// myapp's devcontainer.json is unchanged; web's project is gone.
+	myappDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(myappDir, ".devcontainer"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(myappDir, ".devcontainer", "devcontainer.json"), []byte(`{"image": "fedora:45"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(myappDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envs := []*state.Environment{
+		{ProjectName: "myapp", ProjectDir: myappDir, ConfigHash: cfg.Fingerprint(), Ports: []string{"8080:8080"}, Containers: []state.ContainerRecord{
+			{Name: "envclone-myapp-netns", Role: "netns", ID: "netns1"},
+			{Name: "envclone-myapp-dev", Role: "dev", ID: "dev1"},
+		}},
+		{ProjectName: "web", ProjectDir: filepath.Join(t.TempDir(), "web"), NetNSID: "netns2"},
+	}
+
+	summaries, err := Summarize(context.Background(), rt, envs)
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	var got []string
+	for _, s := range summaries {
+		line := strings.Join([]string{s.Project, s.Status, s.Config, s.StartedAt.Format("15:04")}, " ")
+		if s.Usage != nil {
+			line += fmt.Sprintf(" %.1f%% %d", s.Usage.CPUPercent, s.Usage.MemoryBytes)
+		}
+		got = append(got, line)
+	}
+	want := []string{
+		"myapp degraded current 08:00 0.5% 1048576",
+		"web stopped missing 00:00",
+		"old orphaned unknown 00:00 2.0% 104857600",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("summaries:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
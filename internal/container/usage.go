@@ -0,0 +1,227 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+// ImageUsage is an image an environment runs, or that envclone built or
+// committed for it.
+type ImageUsage struct {
+	Role string `json:"role"` // "dev", "service" or "snapshot"
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	Size int64  `json:"size"`
+	// Shared is the part of Size in the layers of SharedWith, the local
+	// image it is based on, which removing it would not free.
+	Shared     int64  `json:"shared"`
+	SharedWith string `json:"sharedWith,omitempty"`
+}
+
+// Unique returns the space only this image takes.
+func (i ImageUsage) Unique() int64 {
+	return i.Size - i.Shared
+}
+
+// VolumeUsage is a named volume of an environment.
+type VolumeUsage struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"` // -1 if it could not be measured
+}
+
+// ProjectUsage is the disk space a project's environment takes.
+type ProjectUsage struct {
+	Project    string        `json:"project"`
+	ProjectDir string        `json:"projectDir,omitempty"` // empty for orphans
+	Runtime    string        `json:"runtime"`
+	Images     []ImageUsage  `json:"images,omitempty"`
+	Volumes    []VolumeUsage `json:"volumes,omitempty"`
+	// Files are envclone's own files: state, logs and data snapshots.
+	Files state.Usage `json:"files"`
+}
+
+// Total returns the space the project takes, counting only the unique
+// part of its images.
+func (u ProjectUsage) Total() int64 {
+	total := u.Files.Total()
+	for _, img := range u.Images {
+		total += img.Unique()
+	}
+	for _, v := range u.Volumes {
+		total += max(v.Size, 0)
+	}
+	return total
+}
+
+// DiskUsage reports the disk space of the environments recorded for rt,
+// followed by projects that have containers, volumes or images in rt but
+// no state. Volumes are measured with a throwaway helper container each.
+func DiskUsage(ctx context.Context, rt runtime.Runtime, envs []*state.Environment) ([]ProjectUsage, error) {
+	containers, err := rt.List(ctx, "label=envclone.project")
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+	volumes, err := rt.ListVolumes(ctx, "label=envclone.project")
+	if err != nil {
+		return nil, fmt.Errorf("listing volumes: %w", err)
+	}
+	images, err := rt.ListImages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing images: %w", err)
+	}
+
+	var usages []ProjectUsage
+	index := make(map[string]int)
+	add := func(project, dir string) *ProjectUsage {
+		if i, ok := index[project]; ok {
+			return &usages[i]
+		}
+		index[project] = len(usages)
+		usages = append(usages, ProjectUsage{Project: project, ProjectDir: dir, Runtime: rt.Name()})
+		return &usages[len(usages)-1]
+	}
+	for _, env := range envs {
+		u := add(env.ProjectName, env.ProjectDir)
+		if files, err := state.DiskUsage(env.ProjectDir); err == nil {
+			u.Files = files
+		}
+	}
+
+	// Orphans come after the recorded environments, sorted by name.
+	var orphans []string
+	for _, c := range containers {
+		orphans = append(orphans, c.Labels["envclone.project"])
+	}
+	for _, v := range volumes {
+		orphans = append(orphans, v.Labels["envclone.project"])
+	}
+	for _, img := range images {
+		for _, tag := range img.RepoTags {
+			if project, ok := ImageProject(tag); ok {
+				orphans = append(orphans, project)
+			}
+		}
+	}
+	sort.Strings(orphans)
+	for _, project := range orphans {
+		add(project, "")
+	}
+
+	// Images the containers run, then the ones envclone made.
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	for _, c := range containers {
+		role := c.Labels["envclone.role"]
+		if role != "dev" && role != "service" {
+			continue
+		}
+		if img, ok := findImage(images, c.Image); ok {
+			addImage(add(c.Labels["envclone.project"], ""), images, img, role, c.Image)
+		}
+	}
+	for _, img := range images {
+		for _, tag := range img.RepoTags {
+			project, ok := ImageProject(tag)
+			if !ok {
+				continue
+			}
+			role := "dev"
+			if strings.Contains(tag, "-snapshot:") {
+				role = "snapshot"
+			}
+			addImage(add(project, ""), images, img, role, tag)
+		}
+	}
+
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	for _, v := range volumes {
+		u := add(v.Labels["envclone.project"], "")
+		u.Volumes = append(u.Volumes, VolumeUsage{Name: v.Name, Size: volumeSize(ctx, rt, v.Name)})
+	}
+	return usages, nil
+}
+
+// addImage adds img to a project's images unless it is already there,
+// e.g. the built dev image both run and tagged by envclone.
+func addImage(u *ProjectUsage, images []runtime.Image, img runtime.Image, role, name string) {
+	for _, existing := range u.Images {
+		if existing.ID == img.ID {
+			return
+		}
+	}
+	shared, base := sharedSize(images, img)
+	u.Images = append(u.Images, ImageUsage{Role: role, Name: name, ID: img.ID, Size: img.Size, Shared: shared, SharedWith: base})
+}
+
+// sharedSize estimates how much of img it shares with the largest local
+// image it is based on. Runtimes do not report the size of individual
+// layers, so only whole images count: an image whose layers img starts
+// with shares all of its size. Images based on img are not counted, so
+// every layer is attributed to exactly one image.
+func sharedSize(images []runtime.Image, img runtime.Image) (int64, string) {
+	var shared int64
+	var base string
+	for _, other := range images {
+		if other.ID == img.ID || len(other.Layers) == 0 || len(other.Layers) >= len(img.Layers) {
+			continue
+		}
+		if isPrefix(other.Layers, img.Layers) && other.Size > shared {
+			shared = other.Size
+			base = imageName(other)
+		}
+	}
+	return min(shared, img.Size), base
+}
+
+func isPrefix(prefix, layers []string) bool {
+	if len(prefix) > len(layers) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != layers[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func imageName(img runtime.Image) string {
+	if len(img.RepoTags) > 0 {
+		return img.RepoTags[0]
+	}
+	return shortID(strings.TrimPrefix(img.ID, "sha256:"))
+}
+
+// findImage finds the local image a container was created from by
+// reference or ID.
+func findImage(images []runtime.Image, ref string) (runtime.Image, bool) {
+	want := normalizeRef(ref)
+	for _, img := range images {
+		if img.ID == ref || strings.TrimPrefix(img.ID, "sha256:") == ref {
+			return img, true
+		}
+		for _, tag := range img.RepoTags {
+			if normalizeRef(tag) == want {
+				return img, true
+			}
+		}
+	}
+	return runtime.Image{}, false
+}
+
+// normalizeRef brings the ways runtimes spell an image reference to one
+// form, e.g. "docker.io/library/postgres:16" to "postgres:16".
+func normalizeRef(ref string) string {
+	for _, prefix := range []string{"docker.io/library/", "docker.io/", "localhost/"} {
+		ref = strings.TrimPrefix(ref, prefix)
+	}
+	name := ref[strings.LastIndex(ref, "/")+1:]
+	if !strings.Contains(name, ":") && !strings.Contains(name, "@") {
+		ref += ":latest"
+	}
+	return ref
+}
@@ -0,0 +1,76 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/exec/exectest"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+const usageFixture = `
+$ * ps -a -q --no-trunc --filter label=envclone.project
+dev1
+pg1
+$ * container inspect dev1 pg1
+[{"Id": "dev1", "Name": "/envclone-myapp-dev", "Config": {"Image": "envclone-myapp:latest", "Labels": {"envclone.project": "myapp", "envclone.role": "dev"}}},
+ {"Id": "pg1", "Name": "/envclone-myapp-postgres", "Config": {"Image": "docker.io/library/postgres:16", "Labels": {"envclone.project": "myapp", "envclone.role": "service"}}}]
+$ * volume ls -q --filter label=envclone.project
+envclone-myapp-pgdata
+$ * volume inspect envclone-myapp-pgdata
+[{"Name": "envclone-myapp-pgdata", "Labels": {"envclone.project": "myapp"}}]
+$ * run --rm -v envclone-myapp-pgdata:/data:ro * du -sk /data
+100	/data
+$ * image ls -q --no-trunc
+sha256:fedora
+sha256:dev
+sha256:snap
+sha256:pg
+sha256:old
+$ * image inspect sha256:fedora sha256:dev sha256:snap sha256:pg sha256:old
+[{"Id": "sha256:fedora", "RepoTags": ["fedora:45"], "Size": 800, "RootFS": {"Layers": ["l1"]}},
+ {"Id": "sha256:dev", "RepoTags": ["envclone-myapp:latest"], "Size": 1200, "RootFS": {"Layers": ["l1", "l2"]}},
+ {"Id": "sha256:snap", "RepoTags": ["envclone-myapp-snapshot:v1"], "Size": 1300, "RootFS": {"Layers": ["l1", "l2", "l3"]}},
+ {"Id": "sha256:pg", "RepoTags": ["postgres:16"], "Size": 400, "RootFS": {"Layers": ["p1"]}},
+ {"Id": "sha256:old", "RepoTags": ["envclone-old:latest"], "Size": 50, "RootFS": {"Layers": ["o1"]}}]
+`
+
+func TestDiskUsage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	replay, err := exectest.ParseReplay(strings.NewReader(usageFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := runtime.NewDocker(&exectest.Recorder{Replay: replay})
+
+	envs := []*state.Environment{{ProjectName: "myapp", ProjectDir: "/src/myapp"}}
+	usages, err := DiskUsage(context.Background(), rt, envs)
+	if err != nil {
+		t.Fatalf("DiskUsage: %v", err)
+	}
+	var got []string
+	for _, u := range usages {
+		got = append(got, fmt.Sprintf("%s %q total %d", u.Project, u.ProjectDir, u.Total()))
+		for _, img := range u.Images {
+			got = append(got, fmt.Sprintf("  %s %s %d shared %d %s", img.Role, img.Name, img.Size, img.Shared, img.SharedWith))
+		}
+		for _, v := range u.Volumes {
+			got = append(got, fmt.Sprintf("  volume %s %d", v.Name, v.Size))
+		}
+	}
+	want := []string{
+		`myapp "/src/myapp" total 103300`,
+		"  dev envclone-myapp:latest 1200 shared 800 fedora:45",
+		"  service docker.io/library/postgres:16 400 shared 0 ",
+		"  snapshot envclone-myapp-snapshot:v1 1300 shared 1200 envclone-myapp:latest",
+		"  volume envclone-myapp-pgdata 102400",
+		`old "" total 50`,
+		"  dev envclone-old:latest 50 shared 0 ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("usage:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,140 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/matoval/envclone/internal/config"
+	"github.com/matoval/envclone/internal/redact"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+// probeTimeout bounds how long a user's profile scripts may take to run.
+const probeTimeout = 30 * time.Second
+
+// envMarker delimits the probed environment from anything profile scripts
+// print to stdout.
+const envMarker = "__ENVCLONE_ENV__"
+
+// skipEnv lists variables that describe the probing shell itself rather
+// than the user's environment.
+var skipEnv = map[string]bool{
+	"_":        true,
+	"PWD":      true,
+	"OLDPWD":   true,
+	"SHLVL":    true,
+	"HOSTNAME": true,
+	"TERM":     true,
+	"PS1":      true,
+}
+
+// probeUserEnv records the remote user's shell and the variables their
+// profile adds to the environment, following userEnvProbe. Failures only
+// cost the extra environment, so they are reported as warnings.
+func (m *Manager) probeUserEnv(ctx context.Context, env *state.Environment) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	devContainer := fmt.Sprintf("envclone-%s-dev", env.ProjectName)
+
+	env.Shell = m.Config.Shell
+	if env.Shell == "" {
+		env.Shell = m.detectShell(ctx, devContainer, env.RemoteUser)
+	}
+
+	env.UserEnv = nil
+	flags := probeFlags(m.Config.UserEnvProbe)
+	if flags == "" {
+		return
+	}
+
+	base, err := m.readEnv(ctx, devContainer, env.RemoteUser, "cat /proc/self/environ")
+	if err != nil {
+		fmt.Printf("Warning: reading container environment failed: %v\n", err)
+		return
+	}
+	script := fmt.Sprintf("printf %s; cat /proc/self/environ; printf %s", envMarker, envMarker)
+	probed, err := m.readEnv(ctx, devContainer, env.RemoteUser, env.Shell, flags, script)
+	if err != nil {
+		fmt.Printf("Warning: userEnvProbe failed: %v\n", err)
+		return
+	}
+	env.UserEnv = diffEnv(base, probed)
+}
+
+// probeFlags returns the shell flags for a userEnvProbe setting, or "" for
+// none. The spec's default is loginInteractiveShell.
+func probeFlags(probe string) string {
+	switch probe {
+	case config.UserEnvProbeNone:
+		return ""
+	case config.UserEnvProbeLoginShell:
+		return "-lc"
+	case config.UserEnvProbeInteractiveShell:
+		return "-ic"
+	default:
+		return "-lic"
+	}
+}
+
+// detectShell looks up the user's login shell in the container's passwd
+// database, falling back to /bin/sh.
+func (m *Manager) detectShell(ctx context.Context, containerName, user string) string {
+	script := `getent passwd "$1" 2>/dev/null || grep "^$1:" /etc/passwd`
+	out, err := m.Runtime.Exec(ctx, containerName, runtime.ExecOptions{}, "sh", "-c", script, "sh", user)
+	if err == nil {
+		fields := strings.Split(strings.SplitN(out, "\n", 2)[0], ":")
+		if len(fields) == 7 && fields[6] != "" {
+			return fields[6]
+		}
+	}
+	return "/bin/sh"
+}
+
+// readEnv runs command as user and parses the NUL-separated environment it
+// prints. Output outside envMarker delimiters is ignored when present.
+func (m *Manager) readEnv(ctx context.Context, containerName, user string, command ...string) (map[string]string, error) {
+	if len(command) == 1 {
+		command = []string{"sh", "-c", command[0]}
+	}
+	out, err := m.Runtime.Exec(ctx, containerName, runtime.ExecOptions{User: user}, command...)
+	if err != nil {
+		return nil, err
+	}
+
+	if parts := strings.Split(out, envMarker); len(parts) >= 3 {
+		out = parts[1]
+	}
+
+	vars := make(map[string]string)
+	for _, entry := range strings.Split(out, "\x00") {
+		key, value, ok := strings.Cut(entry, "=")
+		if ok && key != "" {
+			vars[key] = value
+		}
+	}
+	return vars, nil
+}
+
+// diffEnv returns the KEY=VALUE pairs in probed that are new or different
+// from base, sorted for stable state files. Variables whose names look
+// like secrets are dropped: the state file is plain text and the pairs are
+// passed as -e on every exec.
+func diffEnv(base, probed map[string]string) []string {
+	var env []string
+	for key, value := range probed {
+		if skipEnv[key] || redact.IsSensitive(key) {
+			continue
+		}
+		if old, ok := base[key]; ok && old == value {
+			continue
+		}
+		env = append(env, key+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,22 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffEnv(t *testing.T) {
+	base := map[string]string{"PATH": "/usr/bin", "HOME": "/home/dev"}
+	probed := map[string]string{
+		"PATH":         "/home/dev/.local/bin:/usr/bin",
+		"HOME":         "/home/dev",
+		"SHLVL":        "2",
+		"EDITOR":       "vim",
+		"GITHUB_TOKEN": "ghp_abc",
+		"AWS_SECRET":   "s3cr3t",
+	}
+	want := []string{"EDITOR=vim", "PATH=/home/dev/.local/bin:/usr/bin"}
+	if got := diffEnv(base, probed); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffEnv = %q, want %q", got, want)
+	}
+}
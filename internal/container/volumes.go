@@ -0,0 +1,124 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/matoval/envclone/internal/runtime"
+)
+
+// VolumeInfo describes a project-scoped named volume.
+type VolumeInfo struct {
+	Name       string // name as written in devcontainer.json, e.g. "pgdata"
+	FullName   string // runtime volume name, e.g. "envclone-myapp-pgdata"
+	Driver     string
+	Mountpoint string
+}
+
+// volumeNamePattern matches the names container runtimes accept for named
+// volumes. Anything else on the left of a volume spec is a host path.
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// VolumeName returns the runtime name of a project-scoped named volume.
+func VolumeName(projectName, name string) string {
+	return fmt.Sprintf("envclone-%s-%s", projectName, name)
+}
+
+// namedVolume reports whether spec ("source:target[:options]") refers to a
+// named volume and, if so, returns its unprefixed name.
+func namedVolume(spec string) (string, bool) {
+	source, _, ok := strings.Cut(spec, ":")
+	if !ok || !volumeNamePattern.MatchString(source) {
+		return "", false
+	}
+	return source, true
+}
+
+// scopeVolume prefixes the name in a volume spec that names a volume with
+// the project and reports the unprefixed name. Host paths and anonymous
+// volumes are returned unchanged.
+func scopeVolume(projectName, spec string) (scoped, name string, ok bool) {
+	name, ok = namedVolume(spec)
+	if !ok {
+		return spec, "", false
+	}
+	return VolumeName(projectName, name) + strings.TrimPrefix(spec, name), name, true
+}
+
+// ensureVolume creates a project-scoped named volume unless it exists.
+func (m *Manager) ensureVolume(ctx context.Context, projectName, name string) error {
+	fullName := VolumeName(projectName, name)
+	_, err := m.Runtime.InspectVolume(ctx, fullName)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, runtime.ErrNotFound) {
+		return fmt.Errorf("inspecting volume %s: %w", name, err)
+	}
+
+	labels := map[string]string{
+		"envclone.project": projectName,
+		"envclone.volume":  name,
+	}
+	if err := m.Runtime.CreateVolume(ctx, fullName, labels); err != nil {
+		return fmt.Errorf("creating volume %s: %w", name, err)
+	}
+	return nil
+}
+
+// Volumes lists the named volumes belonging to the project.
+func (m *Manager) Volumes(ctx context.Context) ([]VolumeInfo, error) {
+	name := m.projectName()
+	volumes, err := m.Runtime.ListVolumes(ctx, fmt.Sprintf("label=envclone.project=%s", name))
+	if err != nil {
+		return nil, fmt.Errorf("listing volumes: %w", err)
+	}
+
+	prefix := VolumeName(name, "")
+	var infos []VolumeInfo
+	for _, v := range volumes {
+		infos = append(infos, VolumeInfo{
+			Name:       strings.TrimPrefix(v.Name, prefix),
+			FullName:   v.Name,
+			Driver:     v.Driver,
+			Mountpoint: v.Mountpoint,
+		})
+	}
+	return infos, nil
+}
+
+// InspectVolume returns a project volume as reported by the runtime.
+func (m *Manager) InspectVolume(ctx context.Context, name string) (*runtime.Volume, error) {
+	vol, err := m.Runtime.InspectVolume(ctx, VolumeName(m.projectName(), name))
+	if err != nil {
+		return nil, fmt.Errorf("inspecting volume %s: %w", name, err)
+	}
+	return vol, nil
+}
+
+// RemoveVolumes removes the given project volumes, or all of them when no
+// names are given. Volumes still mounted by a container cannot be removed.
+func (m *Manager) RemoveVolumes(ctx context.Context, names ...string) error {
+	var fullNames []string
+	if len(names) == 0 {
+		infos, err := m.Volumes(ctx)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			fullNames = append(fullNames, info.FullName)
+		}
+	} else {
+		for _, name := range names {
+			fullNames = append(fullNames, VolumeName(m.projectName(), name))
+		}
+	}
+
+	if err := m.Runtime.RemoveVolumes(ctx, fullNames...); err != nil {
+		return fmt.Errorf("removing volumes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package exectest provides exec.Runner fakes for tests: a Recorder that
+// captures the command lines a test runs, and a Replay that serves canned
+// outputs for them from fixture files.
+package exectest
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the recorded commands")
+
+// Recorder is an exec.Runner that records every command instead of running
+// it. Outputs come from Replay when set; otherwise every command succeeds
+// with no output.
+type Recorder struct {
+	Replay *Replay
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *Recorder) Run(ctx context.Context, name string, args ...string) (string, error) {
+	return r.Replay.respond(r.record(name, args))
+}
+
+func (r *Recorder) Query(ctx context.Context, name string, args ...string) (string, error) {
+	return r.Replay.respond(r.record(name, args))
+}
+
+// RunInput records the command but not its input.
+func (r *Recorder) RunInput(ctx context.Context, input io.Reader, name string, args ...string) (string, error) {
+	return r.Replay.respond(r.record(name, args))
+}
+
+func (r *Recorder) RunInteractive(ctx context.Context, name string, args ...string) error {
+	_, err := r.Replay.respond(r.record(name, args))
+	return err
+}
+
+func (r *Recorder) Stream(ctx context.Context, w io.Writer, name string, args ...string) error {
+	out, err := r.Replay.respond(r.record(name, args))
+	if out != "" {
+		io.WriteString(w, out+"\n")
+	}
+	return err
+}
+
+func (r *Recorder) record(name string, args []string) string {
+	line := CommandLine(name, args...)
+	r.mu.Lock()
+	r.calls = append(r.calls, line)
+	r.mu.Unlock()
+	return line
+}
+
+// Calls returns the command lines recorded so far, formatted by
+// CommandLine.
+func (r *Recorder) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+// Expect fails the test unless the recorded commands are exactly want.
+func (r *Recorder) Expect(t testing.TB, want ...string) {
+	t.Helper()
+	compare(t, "recorded commands", r.Calls(), want)
+}
+
+// Golden compares the recorded commands with the golden file at path, one
+// command per line. Run the tests with -update to rewrite it.
+func (r *Recorder) Golden(t testing.TB, path string) {
+	t.Helper()
+	got := r.Calls()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(got, "\n")+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -update to create it): %v", err)
+	}
+	want := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	compare(t, path, got, want)
+}
+
+func compare(t testing.TB, what string, got, want []string) {
+	t.Helper()
+	n := max(len(got), len(want))
+	for i := 0; i < n; i++ {
+		var g, w string
+		if i < len(got) {
+			g = got[i]
+		}
+		if i < len(want) {
+			w = want[i]
+		}
+		if g != w {
+			t.Errorf("%s differ at command %d:\n got: %s\nwant: %s", what, i+1, g, w)
+			return
+		}
+	}
+}
+
+// CommandLine formats a command as a single line, quoting arguments that
+// contain spaces, quotes or control characters so each command stays on
+// one line of a golden or fixture file.
+func CommandLine(name string, args ...string) string {
+	parts := make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{name}, args...) {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\") || !strconv.CanBackquote(arg) {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
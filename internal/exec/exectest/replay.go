@@ -0,0 +1,128 @@
+package exectest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Replay serves canned command outputs from a fixture. A fixture lists
+// commands, each on a line starting with "$ " and formatted like
+// CommandLine, followed by the command's output:
+//
+//	# lines starting with "#" are comments
+//	$ nerdctl run -d --name envclone-myapp-dev *
+//	dev123
+//	$ * volume inspect envclone-myapp-pgdata
+//	! no such volume: envclone-myapp-pgdata
+//
+// "*" in a command matches any run of characters. Surrounding blank lines
+// are trimmed from outputs. An output line starting
+// with "! " makes the command fail with that message. When several entries
+// match, they are used in order and the last one repeats. Commands that
+// match nothing succeed with no output, unless Strict is set.
+type Replay struct {
+	Strict bool
+
+	mu      sync.Mutex
+	entries []*entry
+}
+
+type entry struct {
+	pattern *regexp.Regexp
+	output  []string
+	err     string
+	used    bool
+}
+
+// LoadReplay reads the fixture at path, failing the test if it is invalid.
+func LoadReplay(t testing.TB, path string) *Replay {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := ParseReplay(f)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return r
+}
+
+// ParseReplay parses a fixture.
+func ParseReplay(r io.Reader) (*Replay, error) {
+	replay := &Replay{}
+	var cur *entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "$ "):
+			cur = &entry{pattern: compilePattern(strings.TrimPrefix(line, "$ "))}
+			replay.entries = append(replay.entries, cur)
+		case cur == nil:
+			if strings.TrimSpace(line) != "" {
+				return nil, fmt.Errorf("line %d: output before the first command", lineNo)
+			}
+		case strings.HasPrefix(line, "! "):
+			cur.err = strings.TrimPrefix(line, "! ")
+		default:
+			cur.output = append(cur.output, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// compilePattern turns a command pattern into an anchored regexp in which
+// "*" matches anything.
+func compilePattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+func (r *Replay) respond(line string) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var match *entry
+	for _, e := range r.entries {
+		if !e.pattern.MatchString(line) {
+			continue
+		}
+		match = e
+		if !e.used {
+			break
+		}
+	}
+	if match == nil {
+		if r.Strict {
+			return "", fmt.Errorf("exectest: unexpected command: %s", line)
+		}
+		return "", nil
+	}
+	match.used = true
+
+	out := strings.TrimSpace(strings.Join(match.output, "\n"))
+	if match.err != "" {
+		return "", errors.New(match.err)
+	}
+	return out, nil
+}
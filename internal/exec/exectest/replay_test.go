@@ -0,0 +1,78 @@
+package exectest
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const fixture = `# leading comments are ignored
+$ nerdctl ps -q
+first
+$ nerdctl ps -q
+# comments are not output
+second
+line two
+$ nerdctl volume inspect *
+! no such volume
+`
+
+func TestReplay(t *testing.T) {
+	replay, err := ParseReplay(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &Recorder{Replay: replay}
+	ctx := context.Background()
+
+	for _, want := range []string{"first", "second\nline two", "second\nline two"} {
+		out, err := rec.Run(ctx, "nerdctl", "ps", "-q")
+		if err != nil || out != want {
+			t.Errorf("ps = %q, %v; want %q", out, err, want)
+		}
+	}
+
+	if _, err := rec.Run(ctx, "nerdctl", "volume", "inspect", "data"); err == nil || err.Error() != "no such volume" {
+		t.Errorf("volume inspect error = %v, want no such volume", err)
+	}
+
+	if out, err := rec.Run(ctx, "nerdctl", "info"); out != "" || err != nil {
+		t.Errorf("unmatched command = %q, %v; want no output", out, err)
+	}
+	replay.Strict = true
+	if _, err := rec.Run(ctx, "nerdctl", "info"); err == nil {
+		t.Error("unmatched command succeeded in strict mode")
+	}
+
+	rec.Expect(t,
+		"nerdctl ps -q",
+		"nerdctl ps -q",
+		"nerdctl ps -q",
+		"nerdctl volume inspect data",
+		"nerdctl info",
+		"nerdctl info",
+	)
+}
+
+func TestParseReplayRejectsLeadingOutput(t *testing.T) {
+	if _, err := ParseReplay(strings.NewReader("output\n$ nerdctl ps\n")); err == nil {
+		t.Error("expected an error for output before the first command")
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"nerdctl", "ps", "-a"}, "nerdctl ps -a"},
+		{[]string{"sh", "-c", "echo hi"}, `sh -c "echo hi"`},
+		{[]string{"printf", "a\nb"}, `printf "a\nb"`},
+		{[]string{"env", ""}, `env ""`},
+	}
+	for _, tt := range tests {
+		if got := CommandLine(tt.args[0], tt.args[1:]...); got != tt.want {
+			t.Errorf("CommandLine(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
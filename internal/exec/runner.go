@@ -4,46 +4,125 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
+
+	"github.com/matoval/envclone/internal/logging"
+	"github.com/matoval/envclone/internal/redact"
 )
 
-type Runner struct {
+// Runner runs external commands. Local runs them on the host; the
+// exectest package provides fakes that record and replay them in tests.
+type Runner interface {
+	// Run runs a command and returns its trimmed stdout. The error
+	// includes stderr.
+	Run(ctx context.Context, name string, args ...string) (string, error)
+	// Query is Run for commands that only read state. They run even in
+	// dry-run mode, so what would be done can be worked out.
+	Query(ctx context.Context, name string, args ...string) (string, error)
+	// RunInput is Run with input fed to the command's stdin. The input is
+	// never logged, so it can carry secrets.
+	RunInput(ctx context.Context, input io.Reader, name string, args ...string) (string, error)
+	// RunInteractive runs a command attached to the caller's stdio.
+	RunInteractive(ctx context.Context, name string, args ...string) error
+	// Stream runs a command, writing its stdout and stderr to w as they
+	// are produced.
+	Stream(ctx context.Context, w io.Writer, name string, args ...string) error
+}
+
+// Local is the Runner that executes commands on the host. With DryRun
+// set, commands other than queries are printed instead of run.
+type Local struct {
 	DryRun bool
 }
 
-func (r *Runner) Run(ctx context.Context, name string, args ...string) (string, error) {
-	log.Printf("exec: %s %s", name, strings.Join(args, " "))
+func (r *Local) Run(ctx context.Context, name string, args ...string) (string, error) {
+	if r.dryRun(name, args) {
+		return "", nil
+	}
+	return r.Query(ctx, name, args...)
+}
 
-	if r.DryRun {
+func (r *Local) Query(ctx context.Context, name string, args ...string) (string, error) {
+	return r.run(ctx, nil, name, args)
+}
+
+func (r *Local) RunInput(ctx context.Context, input io.Reader, name string, args ...string) (string, error) {
+	if r.dryRun(name, args) {
 		return "", nil
 	}
+	return r.run(ctx, input, name, args)
+}
 
+func (r *Local) run(ctx context.Context, input io.Reader, name string, args []string) (string, error) {
 	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = input
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("%s %s: %w\n%s", name, strings.Join(args, " "), err, stderr.String())
+	start := time.Now()
+	err := cmd.Run()
+	logCommand(ctx, "exec", name, args, start, err)
+	slog.Log(ctx, logging.LevelTrace, "exec output", "stdout", redact.String(stdout.String()), "stderr", redact.String(stderr.String()))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w\n%s", redact.Command(name, args), err, redact.String(stderr.String()))
 	}
 
 	return strings.TrimSpace(stdout.String()), nil
 }
 
-func (r *Runner) RunInteractive(ctx context.Context, name string, args ...string) error {
-	log.Printf("exec (interactive): %s %s", name, strings.Join(args, " "))
-
-	if r.DryRun {
+func (r *Local) RunInteractive(ctx context.Context, name string, args ...string) error {
+	if r.dryRun(name, args) {
 		return nil
 	}
-
 	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	return cmd.Run()
+	start := time.Now()
+	err := cmd.Run()
+	logCommand(ctx, "exec (interactive)", name, args, start, err)
+	return err
+}
+
+// Stream is used for long-running output such as container logs.
+func (r *Local) Stream(ctx context.Context, w io.Writer, name string, args ...string) error {
+	if r.dryRun(name, args) {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	start := time.Now()
+	err := cmd.Run()
+	logCommand(ctx, "exec (stream)", name, args, start, err)
+	if err != nil {
+		return fmt.Errorf("%s: %w", redact.Command(name, args), err)
+	}
+	return nil
+}
+
+// logCommand records a finished command at debug level, with secrets
+// masked.
+func logCommand(ctx context.Context, msg, name string, args []string, start time.Time, err error) {
+	attrs := []any{"cmd", redact.Command(name, args), "duration", time.Since(start).Round(time.Millisecond).String()}
+	if err != nil {
+		attrs = append(attrs, "err", err)
+	}
+	slog.DebugContext(ctx, msg, attrs...)
+}
+
+// dryRun prints the command and reports true if it should not be run.
+func (r *Local) dryRun(name string, args []string) bool {
+	if r.DryRun {
+		fmt.Printf("[dry-run] %s\n", redact.Command(name, args))
+	}
+	return r.DryRun
 }
@@ -0,0 +1,149 @@
+// Package logging sets up envclone's slog logger: diagnostics on stderr at
+// the level the user asked for, and a per-project operation log that
+// records everything, including the output of each runtime command.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/matoval/envclone/internal/redact"
+)
+
+// LevelTrace is below debug and carries full command output. Only the
+// operation log records it.
+const LevelTrace = slog.LevelDebug - 4
+
+// Log formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// maxLogSize is the size past which the operation log is started afresh.
+const maxLogSize = 10 << 20
+
+// msgOperationStarted marks the first record of an operation.
+const msgOperationStarted = "operation started"
+
+var (
+	stderrHandler slog.Handler
+	opFile        *os.File
+	// opLogger writes to the operation log only, for the records that
+	// delimit operations.
+	opLogger *slog.Logger
+)
+
+// Setup installs the default logger, writing records at level or above to
+// stderr in the given format.
+func Setup(level slog.Level, format string) error {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: replaceAttr}
+	switch format {
+	case FormatText:
+		stderrHandler = slog.NewTextHandler(os.Stderr, opts)
+	case FormatJSON:
+		stderrHandler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format %q (use text or json)", format)
+	}
+	slog.SetDefault(slog.New(stderrHandler))
+	return nil
+}
+
+// StartOperation appends every record of the current invocation, at all
+// levels, to the operation log at path. command describes the invocation.
+// A failure to open the log is reported on stderr but does not stop the
+// operation.
+func StartOperation(path, command string) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if fi, err := os.Stat(path); err == nil && fi.Size() > maxLogSize {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		slog.Warn("operation log unavailable", "err", err)
+		return
+	}
+	opFile = f
+
+	fileHandler := slog.NewJSONHandler(f, &slog.HandlerOptions{Level: LevelTrace, ReplaceAttr: replaceAttr})
+	slog.SetDefault(slog.New(fanout{stderrHandler, fileHandler}))
+	opLogger = slog.New(fileHandler)
+	opLogger.Info(msgOperationStarted, "command", command, "pid", os.Getpid())
+}
+
+// EndOperation records the outcome of the operation and closes the log.
+func EndOperation(err error, elapsed time.Duration) {
+	if opFile == nil {
+		return
+	}
+	duration := elapsed.Round(time.Millisecond).String()
+	if err != nil {
+		opLogger.Error("operation failed", "err", err, "duration", duration)
+	} else {
+		opLogger.Info("operation finished", "duration", duration)
+	}
+	slog.SetDefault(slog.New(stderrHandler))
+	opFile.Close()
+	opFile = nil
+}
+
+// replaceAttr names LevelTrace, which slog would print as DEBUG-4, and
+// masks secrets in string and error values.
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := a.Value.Any().(slog.Level); ok && level == LevelTrace {
+			return slog.String(slog.LevelKey, "TRACE")
+		}
+		return a
+	}
+	switch v := a.Value.Any().(type) {
+	case string:
+		return slog.String(a.Key, redact.String(v))
+	case error:
+		return slog.String(a.Key, redact.String(v.Error()))
+	}
+	return a
+}
+
+// fanout sends each record to every handler that is enabled for it.
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
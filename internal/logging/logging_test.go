@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteLastOperation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "operations.log")
+	if err := Setup(slog.LevelError, FormatText); err != nil {
+		t.Fatal(err)
+	}
+
+	StartOperation(path, "envclone up")
+	slog.Debug("exec", "cmd", "nerdctl ps -a")
+	EndOperation(nil, time.Second)
+
+	StartOperation(path, "envclone down")
+	slog.Debug("exec", "cmd", "nerdctl rm -f dev")
+	slog.Log(context.Background(), LevelTrace, "exec output", "stdout", "", "stderr", "no such container\nsecond line")
+	EndOperation(errors.New("removing containers"), time.Second)
+
+	var buf bytes.Buffer
+	if err := WriteLastOperation(path, &buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"operation started command=envclone down",
+		"DEBUG exec cmd=nerdctl rm -f dev",
+		"TRACE exec output\n    stderr:\n      no such container\n      second line\n",
+		"ERROR operation failed duration=1s err=removing containers",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("transcript lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "envclone up") || strings.Contains(out, "stdout:") {
+		t.Errorf("transcript includes more than the last operation's records:\n%s", out)
+	}
+}
+
+func TestWriteLastOperationWithoutLog(t *testing.T) {
+	if err := WriteLastOperation(filepath.Join(t.TempDir(), "missing.log"), &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for a missing log")
+	}
+}
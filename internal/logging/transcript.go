@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WriteLastOperation writes a readable transcript of the last operation in
+// the operation log at path to w.
+func WriteLastOperation(path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no operations recorded for this project yet")
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if rec[keyMsg] == msgOperationStarted {
+			records = records[:0]
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading operation log: %w", err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no operations recorded for this project yet")
+	}
+
+	for _, rec := range records {
+		writeRecord(w, rec)
+	}
+	return nil
+}
+
+const (
+	keyTime  = "time"
+	keyLevel = "level"
+	keyMsg   = "msg"
+
+	keyStdout = "stdout"
+	keyStderr = "stderr"
+)
+
+func writeRecord(w io.Writer, rec map[string]any) {
+	ts := fmt.Sprint(rec[keyTime])
+	if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		ts = t.Local().Format("2006-01-02 15:04:05.000")
+	}
+	fmt.Fprintf(w, "%s %-5s %s", ts, rec[keyLevel], rec[keyMsg])
+
+	var keys []string
+	for k := range rec {
+		switch k {
+		case keyTime, keyLevel, keyMsg, keyStdout, keyStderr:
+		default:
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, " %s=%v", k, rec[k])
+	}
+	fmt.Fprintln(w)
+
+	// Command output goes below the record, indented.
+	for _, k := range []string{keyStdout, keyStderr} {
+		if s, _ := rec[k].(string); strings.TrimSpace(s) != "" {
+			fmt.Fprintf(w, "    %s:\n", k)
+			for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+				fmt.Fprintf(w, "      %s\n", line)
+			}
+		}
+	}
+}
@@ -4,37 +4,28 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/matoval/envclone/internal/exec"
-	"github.com/matoval/envclone/internal/platform"
+	"github.com/matoval/envclone/internal/runtime"
 )
 
-// CreateNetNS creates a pause container that provides a shared network namespace.
-// All dev and service containers join this namespace with --network=container:<id>.
-// The SSH port is published so the dev container is reachable from the host.
-func CreateNetNS(ctx context.Context, runner *exec.Runner, plat platform.Platform, projectName string, sshPort int) (string, error) {
-	name := fmt.Sprintf("envclone-%s-netns", projectName)
-	args := plat.NerdctlArgs(
-		"run", "-d",
-		"--name", name,
-		"--hostname", projectName,
-		"-p", fmt.Sprintf("%d:%d", sshPort, sshPort),
-		"--label", fmt.Sprintf("envclone.project=%s", projectName),
-		"--label", "envclone.role=netns",
-		"registry.k8s.io/pause:3.10",
-	)
-
-	id, err := runner.Run(ctx, args[0], args[1:]...)
-	if err != nil {
-		return "", fmt.Errorf("creating network namespace container: %w", err)
+// NetNSOptions describes the pause container that provides a shared network
+// namespace. All dev and service containers join this namespace with
+// --network=container:<name>. The SSH port is published so the dev
+// container is reachable from the host.
+func NetNSOptions(projectName string, sshPort int) runtime.RunOptions {
+	return runtime.RunOptions{
+		Name:     fmt.Sprintf("envclone-%s-netns", projectName),
+		Image:    "registry.k8s.io/pause:3.10",
+		Detach:   true,
+		Hostname: projectName,
+		Ports:    []string{fmt.Sprintf("%d:%d", sshPort, sshPort)},
+		Labels: map[string]string{
+			"envclone.project": projectName,
+			"envclone.role":    "netns",
+		},
 	}
-
-	return id, nil
 }
 
 // RemoveNetNS stops and removes the network namespace container.
-func RemoveNetNS(ctx context.Context, runner *exec.Runner, plat platform.Platform, projectName string) error {
-	name := fmt.Sprintf("envclone-%s-netns", projectName)
-	args := plat.NerdctlArgs("rm", "-f", name)
-	_, err := runner.Run(ctx, args[0], args[1:]...)
-	return err
+func RemoveNetNS(ctx context.Context, rt runtime.Runtime, projectName string) error {
+	return rt.Remove(ctx, fmt.Sprintf("envclone-%s-netns", projectName))
 }
@@ -7,16 +7,33 @@ import (
 	"strings"
 )
 
-type Darwin struct{}
+type Darwin struct {
+	runtime string
+}
 
 func (d *Darwin) Name() string { return "darwin" }
 
+func (d *Darwin) Runtime() string { return d.runtime }
+
 func (d *Darwin) NerdctlArgs(args ...string) []string {
 	limaArgs := []string{"limactl", "shell", "envclone", "--", "nerdctl"}
 	return append(limaArgs, args...)
 }
 
 func (d *Darwin) EnsureRuntime(ctx context.Context) error {
+	switch d.runtime {
+	case "docker":
+		if err := exec.CommandContext(ctx, "docker", "info").Run(); err != nil {
+			return fmt.Errorf("docker is not reachable\nStart Docker Desktop (or colima) first")
+		}
+		return nil
+	case "podman":
+		if err := exec.CommandContext(ctx, "podman", "info").Run(); err != nil {
+			return fmt.Errorf("podman is not reachable\nStart its VM with: podman machine start")
+		}
+		return nil
+	}
+
 	// Check if Lima VM "envclone" exists and is running
 	out, err := exec.CommandContext(ctx, "limactl", "list", "--format", "{{.Name}}:{{.Status}}").Output()
 	if err != nil {
@@ -6,19 +6,79 @@ import (
 	"runtime"
 )
 
-func Detect() (Platform, error) {
+// Runtimes lists the supported container runtimes.
+var Runtimes = []string{"nerdctl", "docker", "podman"}
+
+// Detect returns the platform for the host OS with a container runtime
+// selected. preferred names a runtime to use; when empty, the first one
+// installed is picked, in the order the platform prefers.
+func Detect(preferred string) (Platform, error) {
 	switch runtime.GOOS {
 	case "linux":
-		if _, err := exec.LookPath("nerdctl"); err != nil {
-			return nil, fmt.Errorf("nerdctl not found in PATH\nInstall: https://github.com/containerd/nerdctl#install")
+		rt, err := selectRuntime(preferred, []string{"nerdctl", "podman", "docker"}, "nerdctl")
+		if err != nil {
+			return nil, err
 		}
-		return &Linux{}, nil
+		return &Linux{runtime: rt}, nil
 	case "darwin":
-		if _, err := exec.LookPath("limactl"); err != nil {
-			return nil, fmt.Errorf("lima not found in PATH\nInstall: brew install lima")
+		// nerdctl runs inside the envclone Lima VM rather than on the host.
+		rt, err := selectRuntime(preferred, []string{"nerdctl", "docker", "podman"}, "limactl")
+		if err != nil {
+			return nil, err
 		}
-		return &Darwin{}, nil
+		return &Darwin{runtime: rt}, nil
 	default:
 		return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
 	}
 }
+
+// selectRuntime validates preferred, or picks the first runtime in order
+// whose binary is in PATH. nerdctlBinary is the binary that provides
+// nerdctl on this platform.
+func selectRuntime(preferred string, order []string, nerdctlBinary string) (string, error) {
+	binary := func(rt string) string {
+		if rt == "nerdctl" {
+			return nerdctlBinary
+		}
+		return rt
+	}
+
+	if preferred != "" {
+		if !isRuntime(preferred) {
+			return "", fmt.Errorf("unknown container runtime %q (supported: nerdctl, docker, podman)", preferred)
+		}
+		if _, err := exec.LookPath(binary(preferred)); err != nil {
+			return "", fmt.Errorf("%s not found in PATH\n%s", binary(preferred), installHint(preferred))
+		}
+		return preferred, nil
+	}
+
+	for _, rt := range order {
+		if _, err := exec.LookPath(binary(rt)); err == nil {
+			return rt, nil
+		}
+	}
+	return "", fmt.Errorf("no container runtime found in PATH (tried %s)\n%s\nOr run: envclone setup", order, installHint(order[0]))
+}
+
+func isRuntime(name string) bool {
+	for _, rt := range Runtimes {
+		if rt == name {
+			return true
+		}
+	}
+	return false
+}
+
+func installHint(rt string) string {
+	switch {
+	case rt == "nerdctl" && runtime.GOOS == "darwin":
+		return "Install: brew install lima"
+	case rt == "nerdctl":
+		return "Install: https://github.com/containerd/nerdctl#install"
+	case rt == "docker":
+		return "Install: https://docs.docker.com/engine/install/"
+	default:
+		return "Install: https://podman.io/docs/installation"
+	}
+}
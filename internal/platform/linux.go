@@ -7,15 +7,30 @@ import (
 	"strings"
 )
 
-type Linux struct{}
+type Linux struct {
+	runtime string
+}
 
 func (l *Linux) Name() string { return "linux" }
 
+func (l *Linux) Runtime() string { return l.runtime }
+
 func (l *Linux) NerdctlArgs(args ...string) []string {
 	return append([]string{"nerdctl"}, args...)
 }
 
 func (l *Linux) EnsureRuntime(ctx context.Context) error {
+	switch l.runtime {
+	case "docker":
+		if err := exec.CommandContext(ctx, "docker", "info").Run(); err != nil {
+			return fmt.Errorf("docker daemon is not reachable\nStart it with: sudo systemctl start docker")
+		}
+		return nil
+	case "podman":
+		// podman is daemonless; there is nothing to start.
+		return nil
+	}
+
 	// Check if rootless containerd is running
 	out, err := exec.CommandContext(ctx, "systemctl", "--user", "is-active", "containerd").Output()
 	if err != nil || strings.TrimSpace(string(out)) != "active" {
@@ -4,6 +4,9 @@ import "context"
 
 type Platform interface {
 	Name() string
+	// Runtime returns the container runtime selected for this platform:
+	// "nerdctl", "docker" or "podman".
+	Runtime() string
 	NerdctlArgs(args ...string) []string
 	EnsureRuntime(ctx context.Context) error
 	MountArgs(hostPath, containerPath string) []string
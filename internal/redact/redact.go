@@ -0,0 +1,131 @@
+// Package redact masks secrets in text envclone shows or records: command
+// lines in logs, dry-run output and errors, and 'config show'.
+//
+// Two kinds of values are masked: the value of any KEY=VALUE pair whose key
+// contains one of the patterns (case-insensitively), and any value
+// registered as a secret, wherever it appears.
+package redact
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces redacted values.
+const Mask = "****"
+
+// DefaultPatterns are the key patterns that are always redacted.
+var DefaultPatterns = []string{"PASSWORD", "TOKEN", "SECRET", "KEY"}
+
+var (
+	mu       sync.RWMutex
+	patterns = append([]string(nil), DefaultPatterns...)
+	secrets  []string
+)
+
+// assignment matches KEY=VALUE in free text; VALUE ends at whitespace,
+// quotes and separators.
+var assignment = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_.-]*)=([^\s"',;]+)`)
+
+// argAssignment matches an argument of the form KEY=VALUE or
+// --flag=KEY=VALUE, whose value may contain anything.
+var argAssignment = regexp.MustCompile(`^-{0,2}[A-Za-z_][A-Za-z0-9_.-]*=`)
+
+// AddPatterns adds key patterns to redact, in addition to DefaultPatterns.
+func AddPatterns(p ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range p {
+		if s != "" {
+			patterns = append(patterns, strings.ToUpper(s))
+		}
+	}
+}
+
+// AddSecrets registers values to mask wherever they appear.
+func AddSecrets(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, v := range values {
+		if v != "" {
+			secrets = append(secrets, v)
+		}
+	}
+	// Longest first, so a secret containing another is masked whole.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+}
+
+// Reset restores the default patterns and forgets registered secrets.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	patterns = append([]string(nil), DefaultPatterns...)
+	secrets = nil
+}
+
+// IsSensitive reports whether key matches one of the patterns.
+func IsSensitive(key string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return isSensitive(key)
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToUpper(key)
+	for _, p := range patterns {
+		if strings.Contains(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// String masks sensitive assignments and secrets in free text.
+func String(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return maskSecrets(assignment.ReplaceAllStringFunc(s, maskAssignment))
+}
+
+// Args returns a copy of a command's arguments with sensitive assignments
+// and secrets masked.
+func Args(args []string) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]string, len(args))
+	for i, arg := range args {
+		if argAssignment.MatchString(arg) {
+			arg = maskAssignment(arg)
+		} else {
+			arg = assignment.ReplaceAllStringFunc(arg, maskAssignment)
+		}
+		out[i] = maskSecrets(arg)
+	}
+	return out
+}
+
+// Command formats a command line with its arguments redacted.
+func Command(name string, args []string) string {
+	return strings.Join(append([]string{name}, Args(args)...), " ")
+}
+
+// maskAssignment masks everything after the first sensitive key in a
+// chain of assignments, so both TOKEN=x and --env=TOKEN=x are masked.
+func maskAssignment(s string) string {
+	parts := strings.Split(s, "=")
+	for i, key := range parts[:len(parts)-1] {
+		if isSensitive(strings.TrimLeft(key, "-")) {
+			return strings.Join(parts[:i+1], "=") + "=" + Mask
+		}
+	}
+	return s
+}
+
+func maskSecrets(s string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Mask)
+	}
+	return s
+}
@@ -0,0 +1,51 @@
+package redact
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArgs(t *testing.T) {
+	t.Cleanup(Reset)
+	AddSecrets("hunter2")
+
+	got := Args([]string{
+		"run", "-e", "POSTGRES_PASSWORD=dev", "-e", "POSTGRES_USER=app",
+		"--env=GITHUB_TOKEN=ghp_abc", "--label", "envclone.project=myapp",
+		"sh", "-c", "login --password hunter2", "API_KEY=a b c",
+	})
+	want := []string{
+		"run", "-e", "POSTGRES_PASSWORD=****", "-e", "POSTGRES_USER=app",
+		"--env=GITHUB_TOKEN=****", "--label", "envclone.project=myapp",
+		"sh", "-c", "login --password ****", "API_KEY=****",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Args:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestString(t *testing.T) {
+	t.Cleanup(Reset)
+	AddPatterns("dsn")
+
+	tests := []struct{ in, want string }{
+		{"nerdctl run -e API_KEY=abc123 -e DEBUG=1 img", "nerdctl run -e API_KEY=**** -e DEBUG=1 img"},
+		{`"env": ["AWS_SECRET_ACCESS_KEY=xyz", "REGION=eu"]`, `"env": ["AWS_SECRET_ACCESS_KEY=****", "REGION=eu"]`},
+		{"DATABASE_DSN=postgres://u:p@db/x failed", "DATABASE_DSN=**** failed"},
+		{`"--env=GH_TOKEN=abc"`, `"--env=GH_TOKEN=****"`},
+		{"no assignments here", "no assignments here"},
+	}
+	for _, tt := range tests {
+		if got := String(tt.in); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSecretsLongestFirst(t *testing.T) {
+	t.Cleanup(Reset)
+	AddSecrets("abc", "abcdef")
+	if got := String("token abcdef"); got != "token ****" {
+		t.Errorf("String = %q, want the longer secret masked whole", got)
+	}
+}
@@ -0,0 +1,371 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/matoval/envclone/internal/exec"
+)
+
+// cli implements Runtime on top of a docker-compatible command line.
+type cli struct {
+	name string
+	// command turns runtime arguments into a full command line, e.g. by
+	// prefixing the binary or a VM shell.
+	command func(args ...string) []string
+	runner  exec.Runner
+}
+
+func (c *cli) Name() string { return c.name }
+
+func (c *cli) run(ctx context.Context, args ...string) (string, error) {
+	argv := c.command(args...)
+	return c.runner.Run(ctx, argv[0], argv[1:]...)
+}
+
+// query runs a command that only reads state.
+func (c *cli) query(ctx context.Context, args ...string) (string, error) {
+	argv := c.command(args...)
+	return c.runner.Query(ctx, argv[0], argv[1:]...)
+}
+
+func (c *cli) Build(ctx context.Context, opts BuildOptions) error {
+	_, err := c.run(ctx, "build", "-t", opts.Tag, "-f", opts.Dockerfile, opts.Context)
+	return err
+}
+
+func (c *cli) Run(ctx context.Context, opts RunOptions) (string, error) {
+	args := []string{"run"}
+	if opts.Detach {
+		args = append(args, "-d")
+	}
+	if opts.Remove {
+		args = append(args, "--rm")
+	}
+	if opts.Name != "" {
+		args = append(args, "--name", opts.Name)
+	}
+	if opts.Hostname != "" {
+		args = append(args, "--hostname", opts.Hostname)
+	}
+	for _, p := range opts.Ports {
+		args = append(args, "-p", p)
+	}
+	for _, key := range sortedKeys(opts.Labels) {
+		args = append(args, "--label", key+"="+opts.Labels[key])
+	}
+	if opts.Network != "" {
+		args = append(args, "--network", opts.Network)
+	}
+	for _, e := range opts.Env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, opts.MountArgs...)
+	for _, v := range opts.Volumes {
+		args = append(args, "-v", v)
+	}
+	if opts.Workdir != "" {
+		args = append(args, "-w", opts.Workdir)
+	}
+	if opts.Init {
+		args = append(args, "--init")
+	}
+	for _, t := range opts.Tmpfs {
+		args = append(args, "--tmpfs", t)
+	}
+	if opts.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(opts.CPUs, 'f', -1, 64))
+	}
+	if opts.Memory > 0 {
+		args = append(args, "--memory", strconv.FormatInt(opts.Memory, 10))
+	}
+	if opts.PidsLimit > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(opts.PidsLimit))
+	}
+	args = append(args, opts.ExtraArgs...)
+	args = append(args, opts.Image)
+	args = append(args, opts.Command...)
+	return c.run(ctx, args...)
+}
+
+func execArgs(container string, opts ExecOptions, command []string) []string {
+	args := []string{"exec"}
+	if opts.Detach {
+		args = append(args, "-d")
+	}
+	if opts.Interactive || opts.Stdin != nil {
+		args = append(args, "-i")
+	}
+	if opts.TTY {
+		args = append(args, "-t")
+	}
+	if opts.User != "" {
+		args = append(args, "-u", opts.User)
+	}
+	if opts.Workdir != "" {
+		args = append(args, "-w", opts.Workdir)
+	}
+	for _, e := range opts.Env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, container)
+	return append(args, command...)
+}
+
+func (c *cli) Exec(ctx context.Context, container string, opts ExecOptions, command ...string) (string, error) {
+	if opts.Stdin != nil {
+		argv := c.command(execArgs(container, opts, command)...)
+		return c.runner.RunInput(ctx, opts.Stdin, argv[0], argv[1:]...)
+	}
+	if opts.ReadOnly {
+		return c.query(ctx, execArgs(container, opts, command)...)
+	}
+	return c.run(ctx, execArgs(container, opts, command)...)
+}
+
+func (c *cli) ExecStream(ctx context.Context, container string, opts ExecOptions, w io.Writer, command ...string) error {
+	argv := c.command(execArgs(container, opts, command)...)
+	return c.runner.Stream(ctx, w, argv[0], argv[1:]...)
+}
+
+func (c *cli) ExecInteractive(ctx context.Context, container string, opts ExecOptions, command ...string) error {
+	argv := c.command(execArgs(container, opts, command)...)
+	return c.runner.RunInteractive(ctx, argv[0], argv[1:]...)
+}
+
+func (c *cli) Inspect(ctx context.Context, name string) (*Container, error) {
+	out, err := c.query(ctx, "container", "inspect", name)
+	if err != nil {
+		return nil, notFound(err, name)
+	}
+	containers, err := decodeContainers(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return &containers[0], nil
+}
+
+func (c *cli) List(ctx context.Context, filters ...string) ([]Container, error) {
+	args := []string{"ps", "-a", "-q", "--no-trunc"}
+	for _, f := range filters {
+		args = append(args, "--filter", f)
+	}
+
+	// A container removed between ps and inspect makes inspect fail as a
+	// whole, so a not-found error is worth one retry with a fresh list.
+	for attempt := 0; ; attempt++ {
+		out, err := c.query(ctx, args...)
+		if err != nil {
+			return nil, err
+		}
+		ids := lines(out)
+		if len(ids) == 0 {
+			return nil, nil
+		}
+
+		out, err = c.query(ctx, append([]string{"container", "inspect"}, ids...)...)
+		if err != nil {
+			if err = notFound(err, "container"); errors.Is(err, ErrNotFound) && attempt == 0 {
+				continue
+			}
+			return nil, err
+		}
+		return decodeContainers(out)
+	}
+}
+
+func (c *cli) Info(ctx context.Context) (*Info, error) {
+	out, err := c.query(ctx, "info", "--format", "{{json .}}")
+	if err != nil {
+		return nil, err
+	}
+	return decodeInfo(out)
+}
+
+func (c *cli) Stats(ctx context.Context, names ...string) ([]Stats, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	args := append([]string{"stats", "--no-stream", "--format", "{{json .}}"}, names...)
+	out, err := c.query(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStats(out)
+}
+
+func (c *cli) Top(ctx context.Context, name string) (*Processes, error) {
+	out, err := c.query(ctx, "top", name)
+	if err != nil {
+		return nil, notFound(err, name)
+	}
+	return decodeTop(out), nil
+}
+
+func (c *cli) Remove(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	_, err := c.run(ctx, append([]string{"rm", "-f"}, names...)...)
+	return err
+}
+
+func (c *cli) Logs(ctx context.Context, name string, opts LogOptions, w io.Writer) error {
+	args := []string{"logs"}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if opts.Since != "" {
+		args = append(args, "--since", opts.Since)
+	}
+	if opts.Tail >= 0 {
+		args = append(args, "--tail", strconv.Itoa(opts.Tail))
+	}
+	if opts.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	argv := c.command(append(args, name)...)
+	return c.runner.Stream(ctx, w, argv[0], argv[1:]...)
+}
+
+func (c *cli) Stop(ctx context.Context, name string) error {
+	_, err := c.run(ctx, "stop", name)
+	return err
+}
+
+func (c *cli) Start(ctx context.Context, name string) error {
+	_, err := c.run(ctx, "start", name)
+	return err
+}
+
+func (c *cli) Restart(ctx context.Context, name string) error {
+	_, err := c.run(ctx, "restart", name)
+	return err
+}
+
+func (c *cli) InspectImage(ctx context.Context, image string) (*Image, error) {
+	out, err := c.query(ctx, "image", "inspect", image)
+	if err != nil {
+		return nil, notFound(err, image)
+	}
+	images, err := decodeImages(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("%s: %w", image, ErrNotFound)
+	}
+	return &images[0], nil
+}
+
+func (c *cli) ListImages(ctx context.Context) ([]Image, error) {
+	out, err := c.query(ctx, "image", "ls", "-q", "--no-trunc")
+	if err != nil {
+		return nil, err
+	}
+	// An image with several tags is listed once per tag.
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range lines(out) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	out, err = c.query(ctx, append([]string{"image", "inspect"}, ids...)...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImages(out)
+}
+
+func (c *cli) CopyTo(ctx context.Context, container, src, dst string) error {
+	_, err := c.run(ctx, "cp", src, container+":"+dst)
+	return err
+}
+
+func (c *cli) Commit(ctx context.Context, container, image string, labels map[string]string) error {
+	args := []string{"commit"}
+	for _, key := range sortedKeys(labels) {
+		args = append(args, "--change", fmt.Sprintf("LABEL %s=%s", key, labels[key]))
+	}
+	_, err := c.run(ctx, append(args, container, image)...)
+	return err
+}
+
+func (c *cli) RemoveImage(ctx context.Context, image string) error {
+	_, err := c.run(ctx, "rmi", image)
+	return err
+}
+
+func (c *cli) CreateVolume(ctx context.Context, name string, labels map[string]string) error {
+	args := []string{"volume", "create"}
+	for _, key := range sortedKeys(labels) {
+		args = append(args, "--label", key+"="+labels[key])
+	}
+	_, err := c.run(ctx, append(args, name)...)
+	return err
+}
+
+func (c *cli) InspectVolume(ctx context.Context, name string) (*Volume, error) {
+	out, err := c.query(ctx, "volume", "inspect", name)
+	if err != nil {
+		return nil, notFound(err, name)
+	}
+	volumes, err := decodeVolumes(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(volumes) == 0 {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return &volumes[0], nil
+}
+
+func (c *cli) ListVolumes(ctx context.Context, filters ...string) ([]Volume, error) {
+	args := []string{"volume", "ls", "-q"}
+	for _, f := range filters {
+		args = append(args, "--filter", f)
+	}
+	out, err := c.query(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	names := lines(out)
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	out, err = c.query(ctx, append([]string{"volume", "inspect"}, names...)...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeVolumes(out)
+}
+
+func (c *cli) RemoveVolumes(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	_, err := c.run(ctx, append([]string{"volume", "rm"}, names...)...)
+	return err
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,16 @@
+package runtime
+
+import "github.com/matoval/envclone/internal/exec"
+
+// NewDocker returns a runtime driving the docker CLI, against Docker Engine
+// on Linux or Docker Desktop (or compatible) on macOS.
+func NewDocker(runner exec.Runner) Runtime {
+	return &cli{name: "docker", command: binary("docker"), runner: runner}
+}
+
+// binary returns a command function that prefixes args with name.
+func binary(name string) func(args ...string) []string {
+	return func(args ...string) []string {
+		return append([]string{name}, args...)
+	}
+}
@@ -0,0 +1,346 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned, wrapped, when a container or volume does not
+// exist.
+var ErrNotFound = errors.New("not found")
+
+// notFound wraps ErrNotFound if err is the runtime reporting that name does
+// not exist. nerdctl, docker and podman all phrase this as "no such ...".
+func notFound(err error, name string) error {
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "no such") {
+		return fmt.Errorf("%s: %w", name, ErrNotFound)
+	}
+	return err
+}
+
+// inspectContainer is the subset of docker-compatible inspect output that
+// envclone uses. nerdctl and podman produce the same shape; decoding is
+// case-insensitive, which covers podman's "Id".
+type inspectContainer struct {
+	ID      string `json:"Id"`
+	Name    string
+	Created string
+	Image   string
+	// ImageName is podman's image reference; Image is an ID there.
+	ImageName string
+	State     struct {
+		Status    string
+		Running   bool
+		ExitCode  int
+		StartedAt string
+	}
+	RestartCount int
+	Config       struct {
+		Image  string
+		Labels map[string]string
+	}
+	NetworkSettings struct {
+		Ports map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string
+		}
+	}
+}
+
+// decodeContainers parses the JSON array printed by "container inspect".
+// Empty output, as in dry-run mode, means no containers.
+func decodeContainers(out string) ([]Container, error) {
+	if out == "" {
+		return nil, nil
+	}
+	var raw []inspectContainer
+	if err := json.Unmarshal([]byte(out), &raw); err != nil {
+		return nil, fmt.Errorf("parsing inspect output: %w", err)
+	}
+
+	containers := make([]Container, 0, len(raw))
+	for _, r := range raw {
+		c := Container{
+			ID:           r.ID,
+			Name:         strings.TrimPrefix(r.Name, "/"),
+			Image:        firstNonEmpty(r.Config.Image, r.ImageName, r.Image),
+			Labels:       r.Config.Labels,
+			State:        r.State.Status,
+			Running:      r.State.Running,
+			ExitCode:     r.State.ExitCode,
+			RestartCount: r.RestartCount,
+			Created:      parseTime(r.Created),
+			StartedAt:    parseTime(r.State.StartedAt),
+		}
+		if c.Labels == nil {
+			c.Labels = make(map[string]string)
+		}
+		for spec, bindings := range r.NetworkSettings.Ports {
+			port, proto, _ := strings.Cut(spec, "/")
+			containerPort, err := strconv.Atoi(port)
+			if err != nil {
+				continue
+			}
+			for _, b := range bindings {
+				hostPort, _ := strconv.Atoi(b.HostPort)
+				c.Ports = append(c.Ports, Port{
+					HostIP:        b.HostIP,
+					HostPort:      hostPort,
+					ContainerPort: containerPort,
+					Protocol:      proto,
+				})
+			}
+		}
+		sort.Slice(c.Ports, func(i, j int) bool {
+			return c.Ports[i].ContainerPort < c.Ports[j].ContainerPort
+		})
+		containers = append(containers, c)
+	}
+	return containers, nil
+}
+
+// inspectVolume is the docker-compatible "volume inspect" output.
+type inspectVolume struct {
+	Name       string
+	Driver     string
+	Mountpoint string
+	Labels     map[string]string
+	CreatedAt  string
+}
+
+// decodeVolumes parses the JSON array printed by "volume inspect".
+func decodeVolumes(out string) ([]Volume, error) {
+	if out == "" {
+		return nil, nil
+	}
+	var raw []inspectVolume
+	if err := json.Unmarshal([]byte(out), &raw); err != nil {
+		return nil, fmt.Errorf("parsing volume inspect output: %w", err)
+	}
+
+	volumes := make([]Volume, 0, len(raw))
+	for _, r := range raw {
+		volumes = append(volumes, Volume{
+			Name:       r.Name,
+			Driver:     r.Driver,
+			Mountpoint: r.Mountpoint,
+			Labels:     r.Labels,
+			Created:    parseTime(r.CreatedAt),
+		})
+	}
+	return volumes, nil
+}
+
+// inspectImage is the docker-compatible "image inspect" output.
+type inspectImage struct {
+	ID          string `json:"Id"`
+	RepoTags    []string
+	RepoDigests []string
+	Size        int64
+	Created     string
+	RootFS      struct {
+		Layers []string
+	}
+}
+
+// decodeImages parses the JSON array printed by "image inspect".
+func decodeImages(out string) ([]Image, error) {
+	if out == "" {
+		return nil, nil
+	}
+	var raw []inspectImage
+	if err := json.Unmarshal([]byte(out), &raw); err != nil {
+		return nil, fmt.Errorf("parsing image inspect output: %w", err)
+	}
+
+	images := make([]Image, 0, len(raw))
+	for _, r := range raw {
+		id := r.ID
+		// podman prints the bare hex digest.
+		if id != "" && !strings.Contains(id, ":") {
+			id = "sha256:" + id
+		}
+		images = append(images, Image{ID: id, RepoTags: r.RepoTags, RepoDigests: r.RepoDigests, Size: r.Size, Layers: r.RootFS.Layers, Created: parseTime(r.Created)})
+	}
+	return images, nil
+}
+
+// rawInfo is the output of "info --format '{{json .}}'". docker and
+// nerdctl report Architecture, NCPU, MemTotal and DockerRootDir; podman
+// nests arch, cpus and memTotal under host and graphRoot under store.
+type rawInfo struct {
+	Architecture  string
+	NCPU          int
+	MemTotal      int64
+	DockerRootDir string
+	Host          struct {
+		Arch     string
+		CPUs     int
+		MemTotal int64
+	}
+	Store struct {
+		GraphRoot string
+	}
+}
+
+// decodeInfo parses the output of "info". Empty output, as in tests,
+// means unknown capacity.
+func decodeInfo(out string) (*Info, error) {
+	if out == "" {
+		return nil, nil
+	}
+	var raw rawInfo
+	if err := json.Unmarshal([]byte(out), &raw); err != nil {
+		return nil, fmt.Errorf("parsing info output: %w", err)
+	}
+	info := &Info{
+		Arch:       goArch(firstNonEmpty(raw.Architecture, raw.Host.Arch)),
+		CPUs:       raw.NCPU,
+		Memory:     raw.MemTotal,
+		StorageDir: firstNonEmpty(raw.DockerRootDir, raw.Store.GraphRoot),
+	}
+	if info.CPUs == 0 {
+		info.CPUs = raw.Host.CPUs
+	}
+	if info.Memory == 0 {
+		info.Memory = raw.Host.MemTotal
+	}
+	return info, nil
+}
+
+// goArch translates the kernel's machine names docker and nerdctl report
+// into GOARCH terms, which podman uses already.
+func goArch(machine string) string {
+	switch machine {
+	case "x86_64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "armv7l":
+		return "arm"
+	}
+	return machine
+}
+
+// statsLine is one line of "stats --format '{{json .}}'". Values are
+// formatted for humans, e.g. "12.5%" and "48MiB / 7.6GiB".
+type statsLine struct {
+	ID       string
+	Name     string
+	CPUPerc  string
+	MemUsage string
+	NetIO    string
+	BlockIO  string
+	PIDs     string
+}
+
+// decodeStats parses the output of "stats", one JSON object per line.
+func decodeStats(out string) ([]Stats, error) {
+	var stats []Stats
+	for _, line := range lines(out) {
+		var raw statsLine
+		if err := json.Unmarshal([]byte(line), &raw); err != nil {
+			return nil, fmt.Errorf("parsing stats output: %w", err)
+		}
+		s := Stats{ID: raw.ID, Name: raw.Name}
+		s.CPUPercent, _ = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(raw.CPUPerc), "%"), 64)
+		usage, limit, _ := strings.Cut(raw.MemUsage, "/")
+		s.MemoryUsage, _ = ParseBytes(usage)
+		s.MemoryLimit, _ = ParseBytes(limit)
+		rx, tx, _ := strings.Cut(raw.NetIO, "/")
+		s.NetRx, _ = ParseBytes(rx)
+		s.NetTx, _ = ParseBytes(tx)
+		read, written, _ := strings.Cut(raw.BlockIO, "/")
+		s.BlockRead, _ = ParseBytes(read)
+		s.BlockWrite, _ = ParseBytes(written)
+		s.PIDs, _ = strconv.Atoi(strings.TrimSpace(raw.PIDs))
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// decodeTop parses the table "top" prints: a header of titles and a row
+// per process, split on whitespace. The last column, the command line,
+// keeps its spaces.
+func decodeTop(out string) *Processes {
+	rows := lines(out)
+	if len(rows) == 0 {
+		return &Processes{}
+	}
+	p := &Processes{Titles: strings.Fields(rows[0])}
+	for _, row := range rows[1:] {
+		fields := strings.Fields(row)
+		if n := len(p.Titles); len(fields) > n && n > 0 {
+			fields = append(fields[:n-1], strings.Join(fields[n-1:], " "))
+		}
+		p.Rows = append(p.Rows, fields)
+	}
+	return p
+}
+
+// byteUnits are the size suffixes runtimes print, in both decimal and
+// binary flavours.
+var byteUnits = map[string]float64{
+	"b":  1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40,
+}
+
+// ParseBytes parses a size such as "48MiB", "1.2GB" or "512B".
+func ParseBytes(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i == -1 {
+		i = len(s)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	unit := strings.ToLower(strings.TrimSpace(s[i:]))
+	if unit == "" {
+		unit = "b"
+	}
+	mult, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return uint64(math.Round(n * mult)), nil
+}
+
+// parseTime parses the timestamps runtimes print, returning the zero time
+// for formats it does not recognise rather than failing the whole query.
+func parseTime(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999 -0700 MST"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// lines splits command output into its non-empty lines.
+func lines(out string) []string {
+	var result []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
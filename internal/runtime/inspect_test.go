@@ -0,0 +1,161 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matoval/envclone/internal/exec/exectest"
+)
+
+func TestDecodeContainers(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    Container
+	}{
+		{"inspect_docker.json", Container{
+			ID:           "3f2a9c0d1e",
+			Name:         "envclone-myapp-netns",
+			Image:        "registry.k8s.io/pause:3.10",
+			Labels:       map[string]string{"envclone.project": "myapp", "envclone.role": "netns"},
+			State:        "running",
+			Running:      true,
+			RestartCount: 2,
+			Created:      time.Date(2026, 10, 1, 9, 30, 0, 123456789, time.UTC),
+			StartedAt:    time.Date(2026, 10, 1, 9, 30, 1, 0, time.UTC),
+			Ports: []Port{
+				{HostIP: "0.0.0.0", HostPort: 2222, ContainerPort: 2222, Protocol: "tcp"},
+				{HostIP: "127.0.0.1", HostPort: 18080, ContainerPort: 8080, Protocol: "tcp"},
+			},
+		}},
+		{"inspect_podman.json", Container{
+			ID:        "b71e0f9a22",
+			Name:      "envclone-myapp-postgres",
+			Image:     "docker.io/library/postgres:16",
+			Labels:    map[string]string{"envclone.project": "myapp", "envclone.role": "service"},
+			State:     "exited",
+			ExitCode:  137,
+			Created:   time.Date(2026, 10, 1, 9, 30, 0, 500000000, time.UTC),
+			StartedAt: time.Date(2026, 10, 1, 9, 30, 1, 0, time.UTC),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			containers, err := decodeContainers(string(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(containers) != 1 {
+				t.Fatalf("got %d containers, want 1", len(containers))
+			}
+			got := containers[0]
+			if !got.Created.Equal(tt.want.Created) || !got.StartedAt.Equal(tt.want.StartedAt) {
+				t.Errorf("times = %v, %v; want %v, %v", got.Created, got.StartedAt, tt.want.Created, tt.want.StartedAt)
+			}
+			got.Created, got.StartedAt = tt.want.Created, tt.want.StartedAt
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInspectNotFound(t *testing.T) {
+	replay, err := exectest.ParseReplay(strings.NewReader("$ docker container inspect missing\n! Error: No such container: missing\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := NewDocker(&exectest.Recorder{Replay: replay})
+
+	if _, err := rt.Inspect(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Inspect error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDecodeImages(t *testing.T) {
+	out := `[{"Id": "sha256:9b1f", "RepoTags": ["postgres:16"], "RepoDigests": ["postgres@sha256:77aa"], "Size": 438000000, "RootFS": {"Layers": ["sha256:l1", "sha256:l2"]}, "Created": "2026-09-30T12:00:00Z"},
+	         {"Id": "4c2e", "Created": "2026-09-30 12:00:00 +0000 UTC"}]`
+	images, err := decodeImages(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 || images[0].ID != "sha256:9b1f" || images[0].RepoDigests[0] != "postgres@sha256:77aa" ||
+		images[0].RepoTags[0] != "postgres:16" || images[0].Size != 438000000 || len(images[0].Layers) != 2 {
+		t.Errorf("docker image = %+v", images)
+	}
+	if images[1].ID != "sha256:4c2e" || images[1].Created.IsZero() {
+		t.Errorf("podman image = %+v, want the ID prefixed and the time parsed", images[1])
+	}
+}
+
+func TestDecodeInfo(t *testing.T) {
+	docker, err := decodeInfo(`{"Architecture": "x86_64", "NCPU": 8, "MemTotal": 16777216000, "DockerRootDir": "/var/lib/docker"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Info{Arch: "amd64", CPUs: 8, Memory: 16777216000, StorageDir: "/var/lib/docker"}); *docker != want {
+		t.Errorf("docker info = %+v, want %+v", *docker, want)
+	}
+	podman, err := decodeInfo(`{"host": {"arch": "arm64", "cpus": 4, "memTotal": 8388608000}, "store": {"graphRoot": "/home/me/.local/share/containers/storage"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Info{Arch: "arm64", CPUs: 4, Memory: 8388608000, StorageDir: "/home/me/.local/share/containers/storage"}); *podman != want {
+		t.Errorf("podman info = %+v, want %+v", *podman, want)
+	}
+}
+
+func TestDecodeStats(t *testing.T) {
+	out := `{"BlockIO":"0B / 0B","CPUPerc":"12.50%","ID":"3f2a9c0d1e","MemPerc":"0.61%","MemUsage":"48MiB / 7.5GiB","Name":"envclone-myapp-dev","PIDs":"7"}
+{"ID":"b71e0f9a22","Name":"envclone-myapp-postgres","CPUPerc":"0.00%","MemUsage":"1.5MB / 2GB","NetIO":"1.2kB / 648B","BlockIO":"4.1MB / 12kB","PIDS":"3"}`
+	stats, err := decodeStats(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Stats{
+		{ID: "3f2a9c0d1e", Name: "envclone-myapp-dev", CPUPercent: 12.5, MemoryUsage: 48 << 20, MemoryLimit: 15 << 29, PIDs: 7},
+		{ID: "b71e0f9a22", Name: "envclone-myapp-postgres", MemoryUsage: 1500000, MemoryLimit: 2000000000, NetRx: 1200, NetTx: 648, BlockRead: 4100000, BlockWrite: 12000, PIDs: 3},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("decodeStats:\n got %+v\nwant %+v", stats, want)
+	}
+}
+
+func TestDecodeTop(t *testing.T) {
+	out := `UID    PID    PPID   C    STIME   TTY   TIME       CMD
+root   4242   4221   0    09:12   ?     00:00:00   sleep infinity
+1000   4310   4242   3    09:13   pts/0 00:00:02   go test ./...
+`
+	got := decodeTop(out)
+	want := &Processes{
+		Titles: []string{"UID", "PID", "PPID", "C", "STIME", "TTY", "TIME", "CMD"},
+		Rows: [][]string{
+			{"root", "4242", "4221", "0", "09:12", "?", "00:00:00", "sleep infinity"},
+			{"1000", "4310", "4242", "3", "09:13", "pts/0", "00:00:02", "go test ./..."},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeTop:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	for s, want := range map[string]uint64{"512B": 512, "1kB": 1000, "1.5KiB": 1536, "2GiB": 2 << 30, "0B": 0, "7": 7} {
+		if got, err := ParseBytes(s); err != nil || got != want {
+			t.Errorf("ParseBytes(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+	if _, err := ParseBytes("lots"); err == nil {
+		t.Error("ParseBytes(\"lots\") succeeded")
+	}
+}
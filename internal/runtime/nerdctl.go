@@ -0,0 +1,13 @@
+package runtime
+
+import (
+	"github.com/matoval/envclone/internal/exec"
+	"github.com/matoval/envclone/internal/platform"
+)
+
+// NewNerdctl returns a runtime driving nerdctl with rootless containerd.
+// The platform supplies the command prefix, since on macOS nerdctl runs
+// inside the envclone Lima VM.
+func NewNerdctl(plat platform.Platform, runner exec.Runner) Runtime {
+	return &cli{name: "nerdctl", command: plat.NerdctlArgs, runner: runner}
+}
@@ -0,0 +1,9 @@
+package runtime
+
+import "github.com/matoval/envclone/internal/exec"
+
+// NewPodman returns a runtime driving podman. Rootless podman needs no
+// daemon on Linux; on macOS it talks to its podman machine VM.
+func NewPodman(runner exec.Runner) Runtime {
+	return &cli{name: "podman", command: binary("podman"), runner: runner}
+}
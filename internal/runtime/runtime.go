@@ -0,0 +1,226 @@
+// Package runtime drives the container engine behind an environment.
+// nerdctl, docker and podman are supported; all three are driven through
+// their docker-compatible command lines.
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/matoval/envclone/internal/exec"
+	"github.com/matoval/envclone/internal/platform"
+)
+
+// Runtime is a container engine.
+type Runtime interface {
+	// Name returns "nerdctl", "docker" or "podman".
+	Name() string
+
+	// Build builds an image from a Dockerfile.
+	Build(ctx context.Context, opts BuildOptions) error
+	// Run creates and starts a container and returns its ID, or the
+	// command's output for containers that are not detached.
+	Run(ctx context.Context, opts RunOptions) (string, error)
+	// Exec runs a command in a container and returns its stdout.
+	Exec(ctx context.Context, container string, opts ExecOptions, command ...string) (string, error)
+	// ExecStream runs a command in a container, writing its stdout and
+	// stderr to w as they are produced.
+	ExecStream(ctx context.Context, container string, opts ExecOptions, w io.Writer, command ...string) error
+	// ExecInteractive runs a command in a container attached to the
+	// caller's stdin, stdout and stderr.
+	ExecInteractive(ctx context.Context, container string, opts ExecOptions, command ...string) error
+	// CopyTo copies a file or directory from the host into a container,
+	// following the semantics of "docker cp".
+	CopyTo(ctx context.Context, container, src, dst string) error
+	// Inspect returns the state of a container. The error wraps
+	// ErrNotFound if the container does not exist.
+	Inspect(ctx context.Context, name string) (*Container, error)
+	// List returns all containers, running or not, matching the filters
+	// (e.g. "label=envclone.project=foo").
+	List(ctx context.Context, filters ...string) ([]Container, error)
+	// Info returns the capacity of the host containers run on, which is a
+	// VM on macOS.
+	Info(ctx context.Context) (*Info, error)
+	// Stats returns a snapshot of the resource usage of running
+	// containers.
+	Stats(ctx context.Context, names ...string) ([]Stats, error)
+	// Top lists the processes running in a container.
+	Top(ctx context.Context, name string) (*Processes, error)
+	// Remove force-removes containers.
+	Remove(ctx context.Context, names ...string) error
+	// Logs writes a container's logs to w.
+	Logs(ctx context.Context, name string, opts LogOptions, w io.Writer) error
+
+	Stop(ctx context.Context, name string) error
+	Start(ctx context.Context, name string) error
+	Restart(ctx context.Context, name string) error
+
+	// InspectImage returns a local image. The error wraps ErrNotFound if
+	// the image does not exist.
+	InspectImage(ctx context.Context, image string) (*Image, error)
+	// ListImages returns all local images.
+	ListImages(ctx context.Context) ([]Image, error)
+	// Commit saves a container's filesystem as an image with the given
+	// labels.
+	Commit(ctx context.Context, container, image string, labels map[string]string) error
+	RemoveImage(ctx context.Context, image string) error
+
+	CreateVolume(ctx context.Context, name string, labels map[string]string) error
+	// InspectVolume returns a volume. The error wraps ErrNotFound if the
+	// volume does not exist.
+	InspectVolume(ctx context.Context, name string) (*Volume, error)
+	ListVolumes(ctx context.Context, filters ...string) ([]Volume, error)
+	RemoveVolumes(ctx context.Context, names ...string) error
+}
+
+// BuildOptions describes an image build.
+type BuildOptions struct {
+	Tag        string
+	Dockerfile string
+	Context    string
+}
+
+// RunOptions describes a container to create.
+type RunOptions struct {
+	Name      string
+	Image     string
+	Command   []string
+	Detach    bool
+	Remove    bool // remove the container when it exits
+	Hostname  string
+	Ports     []string // host:container
+	Labels    map[string]string
+	Network   string
+	Env       []string // KEY=VALUE
+	MountArgs []string // raw mount flags, as returned by Platform.MountArgs
+	Volumes   []string // source:target[:options]
+	Workdir   string
+	Init      bool
+	Tmpfs     []string `json:",omitempty"` // target[:options]; left out of hashes when empty
+	CPUs      float64  `json:",omitempty"` // CPU limit, e.g. 1.5
+	Memory    int64    `json:",omitempty"` // memory limit in bytes
+	PidsLimit int      `json:",omitempty"`
+	ExtraArgs []string // passed through verbatim, e.g. runArgs
+}
+
+// ExecOptions describes how to run a command in a container.
+type ExecOptions struct {
+	User        string
+	Workdir     string
+	Env         []string // KEY=VALUE
+	Interactive bool     // keep stdin attached (-i)
+	TTY         bool     // allocate a pseudo-terminal (-t)
+	Detach      bool     // run in the background (-d)
+	// ReadOnly marks a command that only reads state, so it runs in
+	// dry-run mode too.
+	ReadOnly bool
+
+	// Stdin is fed to the command, which implies -i. It is not logged.
+	Stdin io.Reader
+}
+
+// LogOptions selects the log lines to show.
+type LogOptions struct {
+	Follow     bool
+	Since      string
+	Tail       int // lines from the end; negative for all
+	Timestamps bool
+}
+
+// Container is a container as reported by List or Inspect.
+type Container struct {
+	ID           string
+	Name         string
+	Image        string
+	Labels       map[string]string
+	State        string // "created", "running", "exited", ...
+	Running      bool
+	ExitCode     int
+	RestartCount int
+	Created      time.Time
+	StartedAt    time.Time
+	Ports        []Port
+}
+
+// Info is the capacity of the container host.
+type Info struct {
+	Arch   string // in GOARCH terms, e.g. "amd64" or "arm64"
+	CPUs   int
+	Memory int64 // bytes
+	// StorageDir is where the runtime keeps images and containers, on
+	// the container host.
+	StorageDir string
+}
+
+// Stats is the resource usage of a container at one point in time.
+type Stats struct {
+	ID          string
+	Name        string
+	CPUPercent  float64
+	MemoryUsage uint64 // bytes
+	MemoryLimit uint64 // bytes; the host's memory when unlimited
+	NetRx       uint64 // bytes received over the network
+	NetTx       uint64 // bytes sent over the network
+	BlockRead   uint64 // bytes read from block devices
+	BlockWrite  uint64 // bytes written to block devices
+	PIDs        int
+}
+
+// Processes is the process table of a container, as ps prints it inside:
+// the column titles and one row per process.
+type Processes struct {
+	Titles []string
+	Rows   [][]string
+}
+
+// Port is a published container port.
+type Port struct {
+	HostIP        string
+	HostPort      int
+	ContainerPort int
+	Protocol      string
+}
+
+func (p Port) String() string {
+	host := p.HostIP
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	return fmt.Sprintf("%s:%d->%d/%s", host, p.HostPort, p.ContainerPort, p.Protocol)
+}
+
+// Image is an image as reported by InspectImage.
+type Image struct {
+	ID          string // content digest, e.g. "sha256:..."
+	RepoTags    []string
+	RepoDigests []string
+	Size        int64    // bytes, including layers shared with other images
+	Layers      []string // digests of the layers, base image first
+	Created     time.Time
+}
+
+// Volume is a volume as reported by ListVolumes or InspectVolume.
+type Volume struct {
+	Name       string
+	Driver     string
+	Mountpoint string
+	Labels     map[string]string
+	Created    time.Time
+}
+
+// New returns the runtime the platform selected, running its commands
+// through runner.
+func New(plat platform.Platform, runner exec.Runner) (Runtime, error) {
+	switch plat.Runtime() {
+	case "nerdctl":
+		return NewNerdctl(plat, runner), nil
+	case "docker":
+		return NewDocker(runner), nil
+	case "podman":
+		return NewPodman(runner), nil
+	default:
+		return nil, fmt.Errorf("unsupported container runtime %q", plat.Runtime())
+	}
+}
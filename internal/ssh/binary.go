@@ -0,0 +1,75 @@
+package ssh
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+)
+
+// elfMachines are the ELF machine types of the architectures envclone
+// can run its SSH server on.
+var elfMachines = map[string]elf.Machine{
+	"amd64": elf.EM_X86_64,
+	"arm64": elf.EM_AARCH64,
+	"arm":   elf.EM_ARM,
+}
+
+// ServerBinary finds an envclone binary to run as the SSH server in a
+// container of the given architecture (in GOARCH terms; empty means the
+// host's). It must be a statically linked Linux build, so it runs on any
+// image; 'make' and 'make linux' build those. In order, it is:
+//
+//   - $ENVCLONE_SSHD_BINARY
+//   - envclone itself, on a Linux host of the same architecture, if it is
+//     statically linked
+//   - envclone-linux-<arch> next to envclone, e.g. on macOS
+func ServerBinary(arch string) (string, error) {
+	if arch == "" {
+		arch = goruntime.GOARCH
+	}
+	if path := os.Getenv("ENVCLONE_SSHD_BINARY"); path != "" {
+		return path, checkServerBinary(path, arch)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("finding envclone's executable: %w", err)
+	}
+	path := filepath.Join(filepath.Dir(self), "envclone-linux-"+arch)
+	var selfErr error
+	if goruntime.GOOS == "linux" && goruntime.GOARCH == arch {
+		if selfErr = checkServerBinary(self, arch); selfErr == nil {
+			return self, nil
+		}
+	}
+	if _, err := os.Stat(path); err != nil {
+		if selfErr != nil {
+			return "", fmt.Errorf("%w, as 'make' does, or build the server next to envclone with:\n  CGO_ENABLED=0 GOOS=linux GOARCH=%s go build -o %s .", selfErr, arch, path)
+		}
+		return "", fmt.Errorf("the dev container needs a static Linux %s build of envclone for its SSH server; build it next to envclone with 'make linux' or:\n  CGO_ENABLED=0 GOOS=linux GOARCH=%s go build -o %s .", arch, arch, path)
+	}
+	return path, checkServerBinary(path, arch)
+}
+
+// checkServerBinary checks that path is a static Linux executable for
+// arch. A dynamically linked one would fail in images without the same
+// C library, or with none at all.
+func checkServerBinary(path, arch string) error {
+	f, err := elf.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s is not a Linux executable: %w", path, err)
+	}
+	defer f.Close()
+
+	if want, ok := elfMachines[arch]; !ok || f.Machine != want {
+		return fmt.Errorf("%s is built for %s, not %s", path, f.Machine, arch)
+	}
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_INTERP {
+			return fmt.Errorf("%s is dynamically linked, so it cannot run as the SSH server in every image; build it with CGO_ENABLED=0", path)
+		}
+	}
+	return nil
+}
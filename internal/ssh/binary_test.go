@@ -0,0 +1,81 @@
+package ssh
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckServerBinary(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a binary")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	static := filepath.Join(dir, "static")
+	build := exec.Command(goTool, "build", "-o", static, "main.go")
+	build.Dir = dir
+	build.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS=linux", "GOARCH=arm64", "GO111MODULE=off")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building test binary: %v\n%s", err, out)
+	}
+	script := filepath.Join(dir, "script")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path, arch, wantErr string
+	}{
+		{static, "arm64", ""},
+		{static, "amd64", "not amd64"},
+		{script, "arm64", "not a Linux executable"},
+		{"/bin/true", "amd64", "dynamically linked"},
+	}
+	for _, tt := range tests {
+		if tt.path == "/bin/true" && !dynamicTrue() {
+			continue
+		}
+		err := checkServerBinary(tt.path, tt.arch)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("checkServerBinary(%s, %s) = %v, want nil", tt.path, tt.arch, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("checkServerBinary(%s, %s) = %v, want an error containing %q", tt.path, tt.arch, err, tt.wantErr)
+		}
+	}
+
+	t.Setenv("ENVCLONE_SSHD_BINARY", static)
+	if path, err := ServerBinary("arm64"); err != nil || path != static {
+		t.Errorf("ServerBinary = %s, %v; want $ENVCLONE_SSHD_BINARY", path, err)
+	}
+}
+
+// dynamicTrue reports whether /bin/true is a dynamically linked amd64
+// executable, as on most x86-64 Linux distributions.
+func dynamicTrue() bool {
+	f, err := elf.Open("/bin/true")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if f.Machine != elf.EM_X86_64 {
+		return false
+	}
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_INTERP {
+			return true
+		}
+	}
+	return false
+}
@@ -3,58 +3,99 @@ package ssh
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
-	"github.com/matoval/envclone/internal/exec"
-	"github.com/matoval/envclone/internal/platform"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
 )
 
-// SetupSSH installs and configures openssh-server inside the dev container.
-func SetupSSH(ctx context.Context, runner *exec.Runner, plat platform.Platform, containerName, remoteUser string, port int) error {
-	// Install openssh-server
-	installCmd := "apt-get update && apt-get install -y openssh-server || dnf install -y openssh-server || apk add openssh"
-	args := plat.NerdctlArgs("exec", containerName, "sh", "-c", installCmd)
-	if _, err := runner.Run(ctx, args[0], args[1:]...); err != nil {
-		return fmt.Errorf("installing openssh-server: %w", err)
+// ServerDir is where envclone's SSH server lives in the dev container,
+// with its host key, authorized keys, pid file and log.
+const ServerDir = "/.envclone"
+
+// StartServer makes sure envclone's built-in SSH server runs in the dev
+// container on port and accepts pubKey. binary is an envclone built for
+// the container's architecture; it is copied in only when no server is
+// running yet, since the copy would replace the running executable. The
+// server runs as root so sessions can run as whichever user logs in. With
+// dryRun set, nothing is staged on disk; the runtime's dry-run runner
+// prints the commands.
+func StartServer(ctx context.Context, rt runtime.Runtime, containerName, binary string, port int, pubKey string, dryRun bool) error {
+	server := path.Join(ServerDir, "envclone")
+	// The check runs in dry-run mode too, so the output shows whether the
+	// server would be copied and started.
+	check := runtime.ExecOptions{User: "0", ReadOnly: true}
+	_, err := rt.Exec(ctx, containerName, check, server, "sshd", "--check", "--dir", ServerDir)
+	running := err == nil
+
+	// Stage the files under the state directory: it is in the home
+	// directory, which Lima shares with its VM, so nerdctl on macOS can
+	// read them too.
+	stateDir, err := state.Dir()
+	if err != nil {
+		return err
 	}
-
-	// Create sshd run directory
-	args = plat.NerdctlArgs("exec", containerName, "mkdir", "-p", "/run/sshd")
-	if _, err := runner.Run(ctx, args[0], args[1:]...); err != nil {
-		return fmt.Errorf("creating /run/sshd: %w", err)
+	staging := filepath.Join(stateDir, "sshd-staging")
+	if !dryRun {
+		if staging, err = stage(stateDir, binary, pubKey, !running); err != nil {
+			return fmt.Errorf("staging SSH server: %w", err)
+		}
+		defer os.RemoveAll(staging)
 	}
-
-	// Configure sshd
-	sshdConfig := fmt.Sprintf(`Port %d
-PermitRootLogin yes
-PasswordAuthentication no
-PubkeyAuthentication yes
-`, port)
-	args = plat.NerdctlArgs("exec", containerName, "sh", "-c",
-		fmt.Sprintf("echo '%s' > /etc/ssh/sshd_config.d/envclone.conf", sshdConfig))
-	if _, err := runner.Run(ctx, args[0], args[1:]...); err != nil {
-		return fmt.Errorf("configuring sshd: %w", err)
+	// Copying the directory's contents creates ServerDir if needed and
+	// leaves the host key in it alone.
+	if err := rt.CopyTo(ctx, containerName, staging+"/.", ServerDir); err != nil {
+		return fmt.Errorf("copying SSH server into the container: %w", err)
+	}
+	if running {
+		return nil
 	}
 
-	// Generate host keys
-	args = plat.NerdctlArgs("exec", containerName, "ssh-keygen", "-A")
-	if _, err := runner.Run(ctx, args[0], args[1:]...); err != nil {
-		return fmt.Errorf("generating host keys: %w", err)
+	opts := runtime.ExecOptions{User: "0", Detach: true}
+	if _, err := rt.Exec(ctx, containerName, opts, server, "sshd", "--port", strconv.Itoa(port), "--dir", ServerDir); err != nil {
+		return fmt.Errorf("starting SSH server: %w", err)
 	}
+	return nil
+}
 
-	// Start sshd (only if not already running)
-	checkCmd := "pgrep -x sshd > /dev/null 2>&1"
-	args = plat.NerdctlArgs("exec", containerName, "sh", "-c", checkCmd)
-	if _, err := runner.Run(ctx, args[0], args[1:]...); err != nil {
-		args = plat.NerdctlArgs("exec", "-d", containerName, "/usr/sbin/sshd", "-D")
-		if _, err := runner.Run(ctx, args[0], args[1:]...); err != nil {
-			return fmt.Errorf("starting sshd: %w", err)
-		}
+// stage creates a temporary directory in dir holding the authorized key
+// and, with withBinary set, the server binary.
+func stage(dir, binary, pubKey string, withBinary bool) (string, error) {
+	staging, err := os.MkdirTemp(dir, "sshd-")
+	if err != nil {
+		return "", err
+	}
+	err = os.WriteFile(filepath.Join(staging, "authorized_keys"), []byte(pubKey+"\n"), 0o600)
+	if err == nil && withBinary {
+		err = copyFile(binary, filepath.Join(staging, "envclone"), 0o755)
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		return "", err
 	}
+	return staging, nil
+}
 
-	return nil
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
 }
 
 // FindPublicKey searches ~/.ssh/ for the user's public key.
@@ -79,35 +120,3 @@ func FindPublicKey() (string, error) {
 
 	return "", fmt.Errorf("no SSH public key found in ~/.ssh/ (tried id_ed25519.pub, id_rsa.pub, id_ecdsa.pub)\nGenerate one with: ssh-keygen -t ed25519")
 }
-
-// InjectAuthorizedKey adds the given public key to the container's authorized_keys file.
-func InjectAuthorizedKey(ctx context.Context, runner *exec.Runner, plat platform.Platform, containerName, remoteUser, pubKey string) error {
-	sshDir := "/root/.ssh"
-	if remoteUser != "" && remoteUser != "root" {
-		sshDir = fmt.Sprintf("/home/%s/.ssh", remoteUser)
-	}
-	authKeysPath := sshDir + "/authorized_keys"
-
-	// Create .ssh directory with correct permissions
-	mkdirCmd := fmt.Sprintf("mkdir -p %s && chmod 700 %s", sshDir, sshDir)
-	args := plat.NerdctlArgs("exec", containerName, "sh", "-c", mkdirCmd)
-	if _, err := runner.Run(ctx, args[0], args[1:]...); err != nil {
-		return fmt.Errorf("creating .ssh directory: %w", err)
-	}
-
-	// Check if key already exists (idempotent)
-	checkCmd := fmt.Sprintf("grep -qF '%s' %s 2>/dev/null", pubKey, authKeysPath)
-	args = plat.NerdctlArgs("exec", containerName, "sh", "-c", checkCmd)
-	if _, err := runner.Run(ctx, args[0], args[1:]...); err == nil {
-		return nil
-	}
-
-	// Append the key
-	appendCmd := fmt.Sprintf("echo '%s' >> %s && chmod 600 %s", pubKey, authKeysPath, authKeysPath)
-	args = plat.NerdctlArgs("exec", containerName, "sh", "-c", appendCmd)
-	if _, err := runner.Run(ctx, args[0], args[1:]...); err != nil {
-		return fmt.Errorf("injecting authorized key: %w", err)
-	}
-
-	return nil
-}
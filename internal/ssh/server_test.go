@@ -0,0 +1,98 @@
+package ssh
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/matoval/envclone/internal/exec/exectest"
+	"github.com/matoval/envclone/internal/platform"
+	"github.com/matoval/envclone/internal/runtime"
+	"github.com/matoval/envclone/internal/state"
+)
+
+// stagingDir matches the temporary directory StartServer copies from.
+var stagingDir = regexp.MustCompile(`\S*/sshd-\d+/`)
+
+func TestStartServer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	binary := filepath.Join(t.TempDir(), "envclone")
+	if err := os.WriteFile(binary, []byte("#!/bin/true\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	const key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIExample me@host"
+	check := "exec -u 0 envclone-myapp-dev /.envclone/envclone sshd --check --dir /.envclone"
+	cp := "cp STAGING/. envclone-myapp-dev:/.envclone"
+	start := "exec -d -u 0 envclone-myapp-dev /.envclone/envclone sshd --port 2222 --dir /.envclone"
+
+	for _, tc := range []struct {
+		plat   platform.Platform
+		prefix string
+	}{
+		{&platform.Linux{}, "nerdctl "},
+		{&platform.Darwin{}, "limactl shell envclone -- nerdctl "},
+	} {
+		t.Run(tc.plat.Name(), func(t *testing.T) {
+			rec := &exectest.Recorder{Replay: exectest.LoadReplay(t, filepath.Join("testdata", "start.replay"))}
+			if err := StartServer(context.Background(), runtime.NewNerdctl(tc.plat, rec), "envclone-myapp-dev", binary, 2222, key, false); err != nil {
+				t.Fatalf("StartServer: %v", err)
+			}
+			expectCalls(t, rec, tc.prefix+check, tc.prefix+cp, tc.prefix+start)
+		})
+	}
+
+	t.Run("running", func(t *testing.T) {
+		// The server is running, so only the authorized key is copied.
+		rec := &exectest.Recorder{}
+		if err := StartServer(context.Background(), runtime.NewDocker(rec), "envclone-myapp-dev", binary, 2222, key, false); err != nil {
+			t.Fatalf("StartServer: %v", err)
+		}
+		expectCalls(t, rec, "docker "+check, "docker "+cp)
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		// The check still runs, so the copy and start are shown, but
+		// nothing is staged: the binary is not even read.
+		rec := &exectest.Recorder{Replay: exectest.LoadReplay(t, filepath.Join("testdata", "start.replay"))}
+		missing := filepath.Join(t.TempDir(), "missing")
+		if err := StartServer(context.Background(), runtime.NewDocker(dryRunner{rec}), "envclone-myapp-dev", missing, 2222, key, true); err != nil {
+			t.Fatalf("StartServer: %v", err)
+		}
+		stateDir, err := state.Dir()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entries, _ := os.ReadDir(stateDir); len(entries) > 0 {
+			t.Errorf("dry run wrote %s to the state directory", entries[0].Name())
+		}
+		dryCp := "cp " + stateDir + "/sshd-staging/. envclone-myapp-dev:/.envclone"
+		expectCalls(t, rec, "docker "+check, "docker "+dryCp, "docker "+start)
+	})
+}
+
+// dryRunner records commands like exec.Local with DryRun set: only
+// queries are replayed, everything else succeeds without output.
+type dryRunner struct {
+	*exectest.Recorder
+}
+
+func (r dryRunner) Run(ctx context.Context, name string, args ...string) (string, error) {
+	r.Recorder.Run(ctx, name, args...)
+	return "", nil
+}
+
+// expectCalls is Recorder.Expect with the staging directory's random name
+// masked.
+func expectCalls(t *testing.T, rec *exectest.Recorder, want ...string) {
+	t.Helper()
+	var got []string
+	for _, call := range rec.Calls() {
+		got = append(got, stagingDir.ReplaceAllString(call, "STAGING/"))
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
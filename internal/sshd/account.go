@@ -0,0 +1,131 @@
+package sshd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// account is a user of the container, as /etc/passwd describes it.
+type account struct {
+	Name   string
+	UID    uint32
+	GID    uint32
+	Groups []uint32 // supplementary groups, from /etc/group
+	Home   string
+	Shell  string
+}
+
+// root is used for "root" in images that have no /etc/passwd at all,
+// such as distroless ones.
+var root = account{Name: "root", Home: "/root", Shell: "/bin/sh"}
+
+// lookupAccount finds a user in the passwd and group files. Unlike
+// os/user, it returns the login shell, so sessions use the remote user's
+// shell rather than always /bin/sh.
+func lookupAccount(passwd, group, name string) (*account, error) {
+	f, err := os.Open(passwd)
+	if errors.Is(err, os.ErrNotExist) && name == root.Name {
+		acct := root
+		return &acct, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// name:password:uid:gid:gecos:home:shell
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 7 || fields[0] != name {
+			continue
+		}
+		uid, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid uid %q", passwd, fields[2])
+		}
+		gid, err := strconv.ParseUint(fields[3], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid gid %q", passwd, fields[3])
+		}
+		acct := &account{Name: name, UID: uint32(uid), GID: uint32(gid), Home: fields[5], Shell: fields[6]}
+		if acct.Shell == "" || isNologin(acct.Shell) {
+			acct.Shell = "/bin/sh"
+		}
+		acct.Groups = supplementaryGroups(group, name)
+		return acct, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if name == root.Name {
+		acct := root
+		return &acct, nil
+	}
+	return nil, fmt.Errorf("no user %q in %s", name, passwd)
+}
+
+// isNologin reports whether shell refuses logins. Service accounts in
+// images often have one, but in a dev container they should still get a
+// shell.
+func isNologin(shell string) bool {
+	return strings.HasSuffix(shell, "/nologin") || strings.HasSuffix(shell, "/false")
+}
+
+// supplementaryGroups returns the IDs of the groups that list name as a
+// member. A missing or unreadable group file means none.
+func supplementaryGroups(group, name string) []uint32 {
+	f, err := os.Open(group)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var gids []uint32
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// name:password:gid:member,member
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 4 {
+			continue
+		}
+		for _, member := range strings.Split(fields[3], ",") {
+			if member != name {
+				continue
+			}
+			if gid, err := strconv.ParseUint(fields[2], 10, 32); err == nil {
+				gids = append(gids, uint32(gid))
+			}
+		}
+	}
+	return gids
+}
+
+// credential returns what a process needs to run as the account: nil if
+// the server already runs as it, a switch of user if the server runs as
+// root, and an error otherwise.
+func (a *account) credential() (*syscall.Credential, error) {
+	uid := os.Getuid()
+	switch {
+	case uint32(uid) == a.UID:
+		return nil, nil
+	case uid == 0:
+		return &syscall.Credential{Uid: a.UID, Gid: a.GID, Groups: a.Groups}, nil
+	default:
+		return nil, fmt.Errorf("the SSH server runs as uid %d and cannot switch to %s", uid, a.Name)
+	}
+}
+
+// homeDir returns the account's home directory if it exists, and / if
+// not, so sessions always have a working directory.
+func (a *account) homeDir() string {
+	if fi, err := os.Stat(a.Home); err == nil && fi.IsDir() {
+		return a.Home
+	}
+	return "/"
+}
@@ -0,0 +1,167 @@
+package sshd
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// dialTimeout bounds connecting to the target of a local forward.
+const dialTimeout = 10 * time.Second
+
+// directTCPIP is the payload of a "direct-tcpip" channel, a connection
+// the client forwards to an address the server dials (ssh -L), which is
+// how VS Code reaches its server in the container.
+type directTCPIP struct {
+	Host       string
+	Port       uint32
+	OriginHost string
+	OriginPort uint32
+}
+
+func (c *conn) handleDirectTCPIP(newCh ssh.NewChannel) {
+	var req directTCPIP
+	if err := ssh.Unmarshal(newCh.ExtraData(), &req); err != nil {
+		newCh.Reject(ssh.ConnectionFailed, "invalid direct-tcpip request")
+		return
+	}
+	addr := net.JoinHostPort(req.Host, strconv.Itoa(int(req.Port)))
+	target, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		newCh.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		target.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	pipe(ch, target)
+}
+
+// remoteForward is the payload of a "tcpip-forward" request, asking the
+// server to listen and forward connections back to the client (ssh -R).
+type remoteForward struct {
+	Host string
+	Port uint32
+}
+
+// forwardedTCPIP is the payload of the channel a remote forward opens for
+// each connection it accepts.
+type forwardedTCPIP struct {
+	Host       string
+	Port       uint32
+	OriginHost string
+	OriginPort uint32
+}
+
+func (c *conn) handleForward(req *ssh.Request) {
+	var fwd remoteForward
+	if err := ssh.Unmarshal(req.Payload, &fwd); err != nil {
+		req.Reply(false, nil)
+		return
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(fwd.Host, strconv.Itoa(int(fwd.Port))))
+	if err != nil {
+		c.log.Warn("remote forward failed", "host", fwd.Host, "port", fwd.Port, "err", err)
+		req.Reply(false, nil)
+		return
+	}
+	port := uint32(l.Addr().(*net.TCPAddr).Port)
+
+	c.mu.Lock()
+	c.forwards[net.JoinHostPort(fwd.Host, strconv.Itoa(int(port)))] = l
+	if fwd.Port == 0 {
+		// The client asked for any port; it is told which one it got,
+		// and cancels it by that port.
+		c.forwards[net.JoinHostPort(fwd.Host, "0")] = l
+	}
+	c.mu.Unlock()
+
+	var reply []byte
+	if fwd.Port == 0 {
+		reply = ssh.Marshal(struct{ Port uint32 }{port})
+	}
+	req.Reply(true, reply)
+	go c.acceptForwarded(l, fwd.Host, port)
+}
+
+// acceptForwarded sends each connection a remote forward accepts to the
+// client, until the forward is cancelled.
+func (c *conn) acceptForwarded(l net.Listener, host string, port uint32) {
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			origin := nc.RemoteAddr().(*net.TCPAddr)
+			payload := ssh.Marshal(forwardedTCPIP{Host: host, Port: port, OriginHost: origin.IP.String(), OriginPort: uint32(origin.Port)})
+			ch, reqs, err := c.ssh.OpenChannel("forwarded-tcpip", payload)
+			if err != nil {
+				nc.Close()
+				return
+			}
+			go ssh.DiscardRequests(reqs)
+			pipe(ch, nc)
+		}()
+	}
+}
+
+func (c *conn) handleCancelForward(req *ssh.Request) {
+	var fwd remoteForward
+	if err := ssh.Unmarshal(req.Payload, &fwd); err != nil {
+		req.Reply(false, nil)
+		return
+	}
+	c.mu.Lock()
+	l, ok := c.forwards[net.JoinHostPort(fwd.Host, strconv.Itoa(int(fwd.Port)))]
+	for addr, other := range c.forwards {
+		if other == l {
+			delete(c.forwards, addr)
+		}
+	}
+	c.mu.Unlock()
+	if ok {
+		l.Close()
+	}
+	req.Reply(ok, nil)
+}
+
+// closeForwards stops the remote forwards of a closed connection.
+func (c *conn) closeForwards() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for addr, l := range c.forwards {
+		l.Close()
+		delete(c.forwards, addr)
+	}
+}
+
+// pipe copies between a channel and a connection in both directions until
+// both are done, then closes them.
+func pipe(ch ssh.Channel, nc net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(ch, nc)
+		ch.CloseWrite()
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(nc, ch)
+		if cw, ok := nc.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			nc.Close()
+		}
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+	ch.Close()
+	nc.Close()
+}
@@ -0,0 +1,252 @@
+// Package sshd is envclone's built-in SSH server. envclone copies itself
+// into the dev container and runs it there with 'envclone sshd', so VS
+// Code Remote-SSH works against any image, without installing openssh
+// from a package manager. It supports public key authentication, shells
+// and commands with or without a PTY, SFTP, local and remote port
+// forwarding and agent forwarding.
+package sshd
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Files in the server's directory.
+const (
+	HostKeyFile        = "ssh_host_ed25519_key"
+	AuthorizedKeysFile = "authorized_keys"
+	PIDFile            = "sshd.pid"
+	LogFile            = "sshd.log"
+)
+
+// Server serves SSH connections for the users of the container it runs
+// in. Sessions run as the user the client logs in as.
+type Server struct {
+	// Dir holds the host key, which is generated on first start, the
+	// authorized keys and the pid file.
+	Dir string
+	// Addr is the address to listen on, e.g. ":2222".
+	Addr string
+	// Executable is run with SFTPArgs, as the user, for the sftp
+	// subsystem.
+	Executable string
+	SFTPArgs   []string
+	Log        *slog.Logger
+
+	// Passwd and Group are the account databases; empty means the
+	// ones in /etc.
+	Passwd string
+	Group  string
+}
+
+// ListenAndServe listens on s.Addr and serves connections until ctx is
+// cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	config, err := s.config()
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.Dir, PIDFile), []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+		l.Close()
+		return fmt.Errorf("writing pid file: %w", err)
+	}
+	defer os.Remove(filepath.Join(s.Dir, PIDFile))
+	return s.Serve(ctx, l, config)
+}
+
+// Serve accepts connections on l until ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, l net.Listener, config *ssh.ServerConfig) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	s.Log.Info("listening", "addr", l.Addr().String())
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.handleConn(nc, config)
+	}
+}
+
+// Running reports whether a server is running with its pid file in dir.
+func Running(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, PIDFile))
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	err = syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func (s *Server) config() (*ssh.ServerConfig, error) {
+	signer, err := s.hostKey()
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ServerConfig{PublicKeyCallback: s.authorize}
+	config.AddHostKey(signer)
+	return config, nil
+}
+
+// hostKey loads the server's host key, generating it the first time.
+func (s *Server) hostKey() (ssh.Signer, error) {
+	path := filepath.Join(s.Dir, HostKeyFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, genErr := ed25519.GenerateKey(rand.Reader)
+		if genErr != nil {
+			return nil, fmt.Errorf("generating host key: %w", genErr)
+		}
+		block, genErr := ssh.MarshalPrivateKey(key, "envclone")
+		if genErr != nil {
+			return nil, fmt.Errorf("encoding host key: %w", genErr)
+		}
+		data = pem.EncodeToMemory(block)
+		err = os.WriteFile(path, data, 0o600)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("host key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing host key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// authorize accepts a key listed in the server's authorized_keys or in
+// the user's ~/.ssh/authorized_keys. Both are read on every attempt, so
+// keys envclone adds later work without a restart.
+func (s *Server) authorize(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	acct, err := s.lookup(meta.User())
+	if err != nil {
+		return nil, err
+	}
+	want := key.Marshal()
+	for _, path := range []string{filepath.Join(s.Dir, AuthorizedKeysFile), filepath.Join(acct.Home, ".ssh", "authorized_keys")} {
+		if authorizedIn(path, want) {
+			return &ssh.Permissions{Extensions: map[string]string{"fingerprint": ssh.FingerprintSHA256(key)}}, nil
+		}
+	}
+	return nil, fmt.Errorf("key %s is not authorized for %s", ssh.FingerprintSHA256(key), meta.User())
+}
+
+// authorizedIn reports whether the authorized_keys file at path lists the
+// marshalled key.
+func authorizedIn(path string, key []byte) bool {
+	rest, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for len(rest) > 0 {
+		k, _, _, next, err := ssh.ParseAuthorizedKey(rest)
+		if err != nil {
+			return false
+		}
+		if bytes.Equal(k.Marshal(), key) {
+			return true
+		}
+		rest = next
+	}
+	return false
+}
+
+func (s *Server) lookup(name string) (*account, error) {
+	passwd, group := s.Passwd, s.Group
+	if passwd == "" {
+		passwd = "/etc/passwd"
+	}
+	if group == "" {
+		group = "/etc/group"
+	}
+	return lookupAccount(passwd, group, name)
+}
+
+// conn is an authenticated client connection.
+type conn struct {
+	srv  *Server
+	ssh  *ssh.ServerConn
+	acct *account
+	log  *slog.Logger
+
+	mu       sync.Mutex
+	forwards map[string]net.Listener // remote forwards by bind address
+}
+
+func (s *Server) handleConn(nc net.Conn, config *ssh.ServerConfig) {
+	sc, chans, reqs, err := ssh.NewServerConn(nc, config)
+	if err != nil {
+		s.Log.Debug("handshake failed", "remote", nc.RemoteAddr().String(), "err", err)
+		nc.Close()
+		return
+	}
+	log := s.Log.With("user", sc.User(), "remote", sc.RemoteAddr().String())
+	acct, err := s.lookup(sc.User())
+	if err != nil {
+		log.Warn("unknown user", "err", err)
+		sc.Close()
+		return
+	}
+	log.Info("connected", "key", sc.Permissions.Extensions["fingerprint"])
+
+	c := &conn{srv: s, ssh: sc, acct: acct, log: log, forwards: make(map[string]net.Listener)}
+	go c.handleGlobalRequests(reqs)
+	for newCh := range chans {
+		switch newCh.ChannelType() {
+		case "session":
+			go c.handleSession(newCh)
+		case "direct-tcpip":
+			go c.handleDirectTCPIP(newCh)
+		default:
+			newCh.Reject(ssh.UnknownChannelType, "unsupported channel type "+newCh.ChannelType())
+		}
+	}
+	c.closeForwards()
+	log.Info("disconnected")
+}
+
+// handleGlobalRequests answers requests that are not tied to a channel:
+// remote port forwarding, and a refusal for anything else, such as
+// keepalives, which only need an answer.
+func (c *conn) handleGlobalRequests(reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		switch req.Type {
+		case "tcpip-forward":
+			c.handleForward(req)
+		case "cancel-tcpip-forward":
+			c.handleCancelForward(req)
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
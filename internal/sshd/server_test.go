@@ -0,0 +1,326 @@
+package sshd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// TestMain serves SFTP on stdin and stdout when the server runs the test
+// binary for the sftp subsystem, as 'envclone sshd --sftp' does.
+func TestMain(m *testing.M) {
+	if os.Getenv("ENVCLONE_TEST_SFTP") == "1" {
+		server, err := sftp.NewServer(struct {
+			io.Reader
+			io.WriteCloser
+		}{os.Stdin, os.Stdout})
+		if err == nil {
+			err = server.Serve()
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// startServer runs a server for the current user, named "dev", that
+// authorizes the returned client key.
+func startServer(t *testing.T) (addr string, key ssh.Signer) {
+	t.Helper()
+	dir := t.TempDir()
+	home := t.TempDir()
+	passwd := filepath.Join(dir, "passwd")
+	entry := fmt.Sprintf("dev:x:%d:%d::%s:/bin/sh\n", os.Getuid(), os.Getgid(), home)
+	if err := os.WriteFile(passwd, []byte(entry), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err = ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, AuthorizedKeysFile), ssh.MarshalAuthorizedKey(key.PublicKey()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &Server{Dir: dir, Executable: os.Args[0], Passwd: passwd, Group: filepath.Join(dir, "group"), Log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	config, err := srv.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go srv.Serve(ctx, l, config)
+	return l.Addr().String(), key
+}
+
+func dial(t *testing.T, addr, user string, key ssh.Signer) (*ssh.Client, error) {
+	t.Helper()
+	return ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(key)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+}
+
+func TestExec(t *testing.T) {
+	addr, key := startServer(t)
+	client, err := dial(t, addr, "dev", key)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	if err := session.Setenv("GREETING", "hello"); err != nil {
+		t.Fatalf("Setenv: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	session.Stdin = strings.NewReader("from stdin")
+	session.Stdout, session.Stderr = &stdout, &stderr
+	err = session.Run(`echo "$GREETING $USER"; cat; echo oops >&2; exit 3`)
+
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 3 {
+		t.Errorf("Run: err = %v, want exit status 3", err)
+	}
+	if stdout.String() != "hello dev\nfrom stdin" {
+		t.Errorf("stdout = %q, want the env var, user and stdin", stdout.String())
+	}
+	if stderr.String() != "oops\n" {
+		t.Errorf("stderr = %q, want oops", stderr.String())
+	}
+}
+
+func TestPTY(t *testing.T) {
+	addr, key := startServer(t)
+	client, err := dial(t, addr, "dev", key)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	if err := session.RequestPty("xterm-256color", 24, 100, ssh.TerminalModes{}); err != nil {
+		t.Fatalf("RequestPty: %v", err)
+	}
+	out, err := session.Output(`echo "$TERM"; stty size; test -t 0 && echo tty`)
+	if err != nil {
+		t.Fatalf("Output: %v", err)
+	}
+	if got := strings.ReplaceAll(string(out), "\r", ""); got != "xterm-256color\n24 100\ntty\n" {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestSFTP(t *testing.T) {
+	t.Setenv("ENVCLONE_TEST_SFTP", "1")
+	addr, key := startServer(t)
+	client, err := dial(t, addr, "dev", key)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		t.Fatalf("sftp: %v", err)
+	}
+	defer sc.Close()
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	f, err := sc.Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	fmt.Fprint(f, "hello")
+	f.Close()
+	if data, err := os.ReadFile(path); err != nil || string(data) != "hello" {
+		t.Errorf("file written over sftp = %q, %v", data, err)
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	addr, _ := startServer(t)
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	other, _ := ssh.NewSignerFromKey(priv)
+	if client, err := dial(t, addr, "dev", other); err == nil {
+		client.Close()
+		t.Error("an unknown key was accepted")
+	}
+
+	_, key := startServer(t)
+	if client, err := dial(t, addr, "dev", key); err == nil {
+		client.Close()
+		t.Error("another server's key was accepted")
+	}
+}
+
+func TestDirectTCPIP(t *testing.T) {
+	addr, key := startServer(t)
+	client, err := dial(t, addr, "dev", key)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		c, err := echo.Accept()
+		if err == nil {
+			io.Copy(c, c)
+			c.Close()
+		}
+	}()
+
+	conn, err := client.Dial("tcp", echo.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial through the server: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "ping")
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("echo = %q, %v; want ping", buf, err)
+	}
+}
+
+func TestRemoteForward(t *testing.T) {
+	addr, key := startServer(t)
+	client, err := dial(t, addr, "dev", key)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	l, err := client.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen on the server: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			fmt.Fprint(c, "pong")
+			c.Close()
+		}
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if data, err := io.ReadAll(conn); err != nil || string(data) != "pong" {
+		t.Errorf("forwarded = %q, %v; want pong", data, err)
+	}
+}
+
+func TestAgentForwarding(t *testing.T) {
+	addr, key := startServer(t)
+	client, err := dial(t, addr, "dev", key)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	keyring := agent.NewKeyring()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv, Comment: "forwarded"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.ForwardToAgent(client, keyring); err != nil {
+		t.Fatal(err)
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	if err := agent.RequestAgentForwarding(session); err != nil {
+		t.Fatalf("RequestAgentForwarding: %v", err)
+	}
+	// The session prints its agent socket and waits, so the socket
+	// stays up while the test asks the forwarded agent for its keys.
+	stdin, _ := session.StdinPipe()
+	stdout, _ := session.StdoutPipe()
+	if err := session.Start(`echo "$SSH_AUTH_SOCK"; cat`); err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	sock, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("unix", strings.TrimSpace(sock))
+	if err != nil {
+		t.Fatalf("dialing the agent socket: %v", err)
+	}
+	defer conn.Close()
+	keys, err := agent.NewClient(conn).List()
+	if err != nil || len(keys) != 1 || keys[0].Comment != "forwarded" {
+		t.Errorf("forwarded agent keys = %v, %v", keys, err)
+	}
+}
+
+func TestLookupAccount(t *testing.T) {
+	dir := t.TempDir()
+	passwd := filepath.Join(dir, "passwd")
+	group := filepath.Join(dir, "group")
+	os.WriteFile(passwd, []byte("root:x:0:0:root:/root:/bin/bash\nsvc:x:999:999::/nonexistent:/usr/sbin/nologin\ndev:x:1000:1000:Dev:/home/dev:/usr/bin/zsh\n"), 0o644)
+	os.WriteFile(group, []byte("docker:x:998:dev,other\nwheel:x:10:dev\nsvc:x:999:\n"), 0o644)
+
+	dev, err := lookupAccount(passwd, group, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.UID != 1000 || dev.Home != "/home/dev" || dev.Shell != "/usr/bin/zsh" || fmt.Sprint(dev.Groups) != "[998 10]" {
+		t.Errorf("dev = %+v", dev)
+	}
+	if svc, _ := lookupAccount(passwd, group, "svc"); svc == nil || svc.Shell != "/bin/sh" || svc.homeDir() != "/" {
+		t.Errorf("svc = %+v, want /bin/sh and / for its nologin shell and missing home", svc)
+	}
+	if _, err := lookupAccount(passwd, group, "nobody"); err == nil {
+		t.Error("lookupAccount found a user not in passwd")
+	}
+	// Distroless images have no passwd, but root still works.
+	if root, err := lookupAccount(filepath.Join(dir, "missing"), group, "root"); err != nil || root.UID != 0 {
+		t.Errorf("root without passwd = %+v, %v", root, err)
+	}
+}
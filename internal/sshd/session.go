@@ -0,0 +1,379 @@
+package sshd
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/crypto/ssh"
+)
+
+// outputGrace is how long a finished command's output is still forwarded
+// while background processes it started hold the output open.
+const outputGrace = time.Second
+
+// ptyRequest is the payload of a "pty-req" request.
+type ptyRequest struct {
+	Term   string
+	Cols   uint32
+	Rows   uint32
+	Width  uint32
+	Height uint32
+	Modes  string
+}
+
+// windowChange is the payload of a "window-change" request.
+type windowChange struct {
+	Cols   uint32
+	Rows   uint32
+	Width  uint32
+	Height uint32
+}
+
+// signals maps SSH signal names to the signals "signal" requests send.
+var signals = map[string]syscall.Signal{
+	"HUP": syscall.SIGHUP, "INT": syscall.SIGINT, "QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL, "TERM": syscall.SIGTERM, "USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// session is a "session" channel: one shell, command or subsystem, with
+// the environment, PTY and agent forwarding the client asked for first.
+type session struct {
+	*conn
+	ch  ssh.Channel
+	env []string
+
+	pty  *ptyRequest
+	ptmx *os.File
+
+	agentDir      string
+	agentListener net.Listener
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	exited bool
+}
+
+func (c *conn) handleSession(newCh ssh.NewChannel) {
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		return
+	}
+	s := &session{conn: c, ch: ch}
+	defer s.cleanup()
+
+	for req := range reqs {
+		ok := false
+		switch req.Type {
+		case "env":
+			var kv struct{ Name, Value string }
+			if ssh.Unmarshal(req.Payload, &kv) == nil {
+				s.env = append(s.env, kv.Name+"="+kv.Value)
+				ok = true
+			}
+		case "pty-req":
+			var p ptyRequest
+			if ssh.Unmarshal(req.Payload, &p) == nil {
+				s.pty, ok = &p, true
+			}
+		case "window-change":
+			var w windowChange
+			if ssh.Unmarshal(req.Payload, &w) == nil && s.ptmx != nil {
+				ok = pty.Setsize(s.ptmx, &pty.Winsize{Cols: uint16(w.Cols), Rows: uint16(w.Rows)}) == nil
+			}
+		case "auth-agent-req@openssh.com":
+			ok = s.forwardAgent() == nil
+		case "signal":
+			var sig struct{ Signal string }
+			if ssh.Unmarshal(req.Payload, &sig) == nil {
+				ok = s.signal(signals[sig.Signal])
+			}
+		case "shell", "exec", "subsystem":
+			ok = s.start(req) == nil
+		}
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+	}
+}
+
+// start runs the session's program as the user: their login shell, a
+// command through that shell, or the sftp server.
+func (s *session) start(req *ssh.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd != nil {
+		return errors.New("session already started")
+	}
+
+	shell := s.acct.Shell
+	var cmd *exec.Cmd
+	switch req.Type {
+	case "shell":
+		cmd = exec.Command(shell)
+		// A leading dash makes it a login shell, as sshd does.
+		cmd.Args[0] = "-" + filepath.Base(shell)
+	case "exec":
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			return err
+		}
+		cmd = exec.Command(shell, "-c", payload.Command)
+	case "subsystem":
+		var payload struct{ Name string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			return err
+		}
+		if payload.Name != "sftp" || s.srv.Executable == "" {
+			return errors.New("unsupported subsystem " + payload.Name)
+		}
+		cmd = exec.Command(s.srv.Executable, s.srv.SFTPArgs...)
+		s.pty = nil
+	}
+
+	cred, err := s.acct.credential()
+	if err != nil {
+		s.log.Warn("cannot start session", "err", err)
+		return err
+	}
+	cmd.Dir = s.acct.homeDir()
+	cmd.Env = s.environ()
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cred, Setsid: true}
+
+	if s.pty != nil {
+		err = s.startPTY(cmd)
+	} else {
+		err = s.startPipes(cmd)
+	}
+	if err != nil {
+		s.log.Warn("cannot start session", "type", req.Type, "err", err)
+		return err
+	}
+	s.cmd = cmd
+	s.log.Debug("session started", "type", req.Type, "pid", cmd.Process.Pid, "pty", s.pty != nil)
+	return nil
+}
+
+// startPTY runs cmd on a new terminal owned by the user, with the size
+// the client asked for.
+func (s *session) startPTY(cmd *exec.Cmd) error {
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+	if err := tty.Chown(int(s.acct.UID), int(s.acct.GID)); err != nil && os.Getuid() == 0 {
+		ptmx.Close()
+		return err
+	}
+	pty.Setsize(ptmx, &pty.Winsize{Cols: uint16(s.pty.Cols), Rows: uint16(s.pty.Rows)})
+
+	cmd.Env = append(cmd.Env, "SSH_TTY="+tty.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	cmd.SysProcAttr.Setctty = true
+	if err := cmd.Start(); err != nil {
+		ptmx.Close()
+		return err
+	}
+	s.ptmx = ptmx
+
+	go io.Copy(ptmx, s.ch)
+	output := make(chan struct{})
+	go func() {
+		// Reading fails with EIO once every process has closed the
+		// terminal.
+		io.Copy(s.ch, ptmx)
+		close(output)
+	}()
+	go func() {
+		err := cmd.Wait()
+		select {
+		case <-output:
+		case <-time.After(outputGrace):
+		}
+		s.exit(err)
+	}()
+	return nil
+}
+
+// startPipes runs cmd with its stdin, stdout and stderr connected to the
+// channel.
+func (s *session) startPipes(cmd *exec.Cmd) error {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = s.ch
+	cmd.Stderr = s.ch.Stderr()
+	cmd.WaitDelay = outputGrace
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		io.Copy(stdin, s.ch)
+		stdin.Close()
+	}()
+	go func() {
+		s.exit(cmd.Wait())
+	}()
+	return nil
+}
+
+// exit reports how the session's program ended and closes the channel.
+func (s *session) exit(err error) {
+	s.mu.Lock()
+	s.exited = true
+	s.mu.Unlock()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		s.ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+	case errors.As(err, &exitErr):
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			s.ch.SendRequest("exit-signal", false, ssh.Marshal(struct {
+				Signal     string
+				CoreDumped bool
+				Message    string
+				Lang       string
+			}{signalName(status.Signal()), status.CoreDump(), "", ""}))
+		} else {
+			s.ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(exitErr.ExitCode())}))
+		}
+	default:
+		s.log.Debug("session ended", "err", err)
+		s.ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{255}))
+	}
+	s.ch.Close()
+}
+
+func signalName(sig syscall.Signal) string {
+	for name, s := range signals {
+		if s == sig {
+			return name
+		}
+	}
+	return "KILL"
+}
+
+// signal sends sig to the session's program and everything it started.
+func (s *session) signal(sig syscall.Signal) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sig == 0 || s.cmd == nil {
+		return false
+	}
+	// Setsid makes the program the leader of its own process group.
+	return syscall.Kill(-s.cmd.Process.Pid, sig) == nil
+}
+
+// cleanup hangs up a program still running when the client closes the
+// session, and stops agent forwarding. Background processes of a program
+// that exited, such as the VS Code server, keep running.
+func (s *session) cleanup() {
+	s.mu.Lock()
+	running := s.cmd != nil && !s.exited
+	s.mu.Unlock()
+	if running {
+		s.signal(syscall.SIGHUP)
+	}
+	if s.ptmx != nil {
+		s.ptmx.Close()
+	}
+	if s.agentListener != nil {
+		s.agentListener.Close()
+		os.RemoveAll(s.agentDir)
+	}
+	s.ch.Close()
+}
+
+// environ returns the environment of the session's program: the
+// container's, as the server was started with, then the user's
+// identity, then what the client sent.
+func (s *session) environ() []string {
+	env := os.Environ()
+	set := func(key, value string) {
+		for i, kv := range env {
+			if strings.HasPrefix(kv, key+"=") {
+				env[i] = key + "=" + value
+				return
+			}
+		}
+		env = append(env, key+"="+value)
+	}
+
+	set("HOME", s.acct.Home)
+	set("USER", s.acct.Name)
+	set("LOGNAME", s.acct.Name)
+	set("SHELL", s.acct.Shell)
+	if s.pty != nil && s.pty.Term != "" {
+		set("TERM", s.pty.Term)
+	}
+	remoteHost, remotePort, _ := net.SplitHostPort(s.ssh.RemoteAddr().String())
+	localHost, localPort, _ := net.SplitHostPort(s.ssh.LocalAddr().String())
+	set("SSH_CLIENT", strings.Join([]string{remoteHost, remotePort, localPort}, " "))
+	set("SSH_CONNECTION", strings.Join([]string{remoteHost, remotePort, localHost, localPort}, " "))
+	if s.agentDir != "" {
+		set("SSH_AUTH_SOCK", filepath.Join(s.agentDir, "agent.sock"))
+	}
+	for _, kv := range s.env {
+		key, value, _ := strings.Cut(kv, "=")
+		set(key, value)
+	}
+	return env
+}
+
+// forwardAgent listens on a socket only the user can reach and forwards
+// each connection to the client's agent over an
+// "auth-agent@openssh.com" channel.
+func (s *session) forwardAgent() error {
+	if s.agentDir != "" {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "envclone-agent-")
+	if err != nil {
+		return err
+	}
+	sock := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	for _, path := range []string{dir, sock} {
+		if err := os.Chown(path, int(s.acct.UID), int(s.acct.GID)); err != nil && os.Getuid() == 0 {
+			l.Close()
+			os.RemoveAll(dir)
+			return err
+		}
+	}
+	s.agentDir, s.agentListener = dir, l
+
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				ch, reqs, err := s.ssh.OpenChannel("auth-agent@openssh.com", nil)
+				if err != nil {
+					nc.Close()
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				pipe(ch, nc)
+			}()
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,134 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrLocked is returned, wrapped, by Lock when another process holds the
+// project's lock.
+var ErrLocked = errors.New("another envclone operation is in progress")
+
+// Lock is an exclusive lock on a project's environment, held by commands
+// that change it for as long as they run.
+type Lock struct {
+	f *os.File
+}
+
+// AcquireLock takes the project's lock without waiting. The lock file
+// records the holder's pid for the error other processes get, and the
+// project directory for Busy. The kernel releases the lock when the
+// process exits, so a crash cannot leave it stale.
+func AcquireLock(projectDir string) (*Lock, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	lockDir := filepath.Join(dir, "locks")
+	if err := os.MkdirAll(lockDir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(lockDir, projectKey(projectDir)+".lock")
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		defer f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, lockedError(f)
+		}
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(fmt.Sprintf("%d\n%s\n", os.Getpid(), projectDir)), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// lockedError reports the pid recorded by the lock's holder, if any.
+func lockedError(f *os.File) error {
+	data, _ := os.ReadFile(f.Name())
+	first, _, _ := strings.Cut(string(data), "\n")
+	if pid, err := strconv.Atoi(strings.TrimSpace(first)); err == nil {
+		return fmt.Errorf("%w (pid %d)", ErrLocked, pid)
+	}
+	return ErrLocked
+}
+
+// Busy returns the directories of the projects whose lock another process
+// holds, i.e. that an operation is changing right now.
+func Busy() ([]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "locks", "*.lock"))
+	if err != nil {
+		return nil, err
+	}
+	var busy []string
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+		f.Close()
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			continue
+		}
+		data, _ := os.ReadFile(path)
+		lines := strings.Split(string(data), "\n")
+		if len(lines) > 1 && lines[1] != "" {
+			busy = append(busy, lines[1])
+		}
+	}
+	return busy, nil
+}
+
+// Release gives up the lock.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	l.f.Truncate(0)
+	return l.f.Close()
+}
+
+// writeFile replaces path with data atomically: readers see either the
+// old or the new contents, never a partial write.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
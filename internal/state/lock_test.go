@@ -0,0 +1,71 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAcquireLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	lock, err := AcquireLock("/src/myapp")
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+
+	// flock locks belong to the open file, so a second open conflicts
+	// even within one process.
+	_, err = AcquireLock("/src/myapp")
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("second AcquireLock = %v, want ErrLocked", err)
+	}
+	if want := fmt.Sprintf("(pid %d)", os.Getpid()); !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not name the holder %s", err, want)
+	}
+
+	if busy, err := Busy(); err != nil || strings.Join(busy, " ") != "/src/myapp" {
+		t.Errorf("Busy = %v, %v; want [/src/myapp]", busy, err)
+	}
+
+	if other, err := AcquireLock("/src/other"); err != nil {
+		t.Errorf("locking another project: %v", err)
+	} else {
+		other.Release()
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if busy, _ := Busy(); len(busy) != 0 {
+		t.Errorf("Busy after Release = %v, want none", busy)
+	}
+	lock, err = AcquireLock("/src/myapp")
+	if err != nil {
+		t.Fatalf("AcquireLock after Release: %v", err)
+	}
+	lock.Release()
+}
+
+func TestSaveIsAtomic(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	env := &Environment{ProjectName: "myapp", DevContainerID: "dev123"}
+	if err := Save("/src/myapp", env); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := Load("/src/myapp")
+	if err != nil || got.DevContainerID != "dev123" {
+		t.Fatalf("Load = %+v, %v", got, err)
+	}
+
+	// No temp files are left next to the state file.
+	dir, _ := Dir()
+	matches, _ := filepath.Glob(filepath.Join(dir, ".*"))
+	if len(matches) != 0 {
+		t.Errorf("leftover temp files: %v", matches)
+	}
+}
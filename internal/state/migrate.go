@@ -0,0 +1,38 @@
+package state
+
+import "fmt"
+
+// SchemaVersion is the version of the state file format this envclone
+// writes. Bump it, and add a migration, whenever a change needs existing
+// state files to be rewritten.
+const SchemaVersion = 1
+
+// migrations[n] upgrades an environment from schema n to n+1.
+var migrations = []func(*Environment){
+	migrateV0,
+}
+
+// migrate upgrades env, loaded from a file of an older schema, to
+// SchemaVersion.
+func migrate(env *Environment) error {
+	if env.SchemaVersion > SchemaVersion {
+		return fmt.Errorf("state was written by a newer envclone (schema %d, this one supports %d)", env.SchemaVersion, SchemaVersion)
+	}
+	for v := env.SchemaVersion; v < SchemaVersion; v++ {
+		migrations[v](env)
+	}
+	env.SchemaVersion = SchemaVersion
+	return nil
+}
+
+// migrateV0 upgrades state from before schema versions, which predates
+// the runtime and status fields. Container records are filled in by the
+// next up.
+func migrateV0(env *Environment) {
+	if env.Runtime == "" {
+		env.Runtime = "nerdctl"
+	}
+	if env.Status == "" {
+		env.Status = StatusRunning
+	}
+}
@@ -0,0 +1,45 @@
+package state
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func writeState(t *testing.T, projectDir, data string) {
+	t.Helper()
+	path, err := stateFile(projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadMigratesV0(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	// State as written before schema versions, runtimes and status.
+	writeState(t, "/src/myapp", `{"projectName": "myapp", "devContainerID": "dev123", "netNSID": "netns123", "sshPort": 2222}`)
+
+	env, err := Load("/src/myapp")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if env.SchemaVersion != SchemaVersion || env.Runtime != "nerdctl" || env.Status != StatusRunning {
+		t.Errorf("migrated state = schema %d, runtime %q, status %q", env.SchemaVersion, env.Runtime, env.Status)
+	}
+	if env.DevContainerID != "dev123" {
+		t.Errorf("DevContainerID = %q, want it kept", env.DevContainerID)
+	}
+}
+
+func TestLoadRejectsNewerSchema(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeState(t, "/src/myapp", `{"schemaVersion": 99, "projectName": "myapp"}`)
+
+	_, err := Load("/src/myapp")
+	if err == nil || !strings.Contains(err.Error(), "newer envclone") {
+		t.Fatalf("Load = %v, want a newer-schema error", err)
+	}
+}
@@ -0,0 +1,68 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Snapshot records a dev container committed to an image with
+// 'envclone snapshot'.
+type Snapshot struct {
+	Tag        string    `json:"tag"`
+	Image      string    `json:"image"`
+	ConfigHash string    `json:"configHash"`
+	Created    time.Time `json:"created"`
+}
+
+func snapshotsFile(projectDir string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snapshots", projectKey(projectDir)+".json"), nil
+}
+
+// LoadSnapshots returns the project's recorded snapshots, oldest first.
+func LoadSnapshots(projectDir string) ([]Snapshot, error) {
+	path, err := snapshotsFile(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []Snapshot
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// SaveSnapshots replaces the project's recorded snapshots.
+func SaveSnapshots(projectDir string, snapshots []Snapshot) error {
+	path, err := snapshotsFile(projectDir)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+// FindSnapshot returns the snapshot with the given tag, if recorded.
+func FindSnapshot(snapshots []Snapshot, tag string) (Snapshot, bool) {
+	for _, s := range snapshots {
+		if s.Tag == tag {
+			return s, true
+		}
+	}
+	return Snapshot{}, false
+}
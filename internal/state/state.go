@@ -3,12 +3,26 @@ package state
 import (
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Environment status values.
+const (
+	StatusRunning = "running"
+	StatusFailed  = "failed"
 )
 
 type Environment struct {
+	SchemaVersion   int    `json:"schemaVersion"`
+	EnvcloneVersion string `json:"envcloneVersion,omitempty"`
+
 	ProjectName    string   `json:"projectName"`
 	ProjectDir     string   `json:"projectDir"`
 	DevContainerID string   `json:"devContainerID"`
@@ -16,9 +30,100 @@ type Environment struct {
 	ServiceIDs     []string `json:"serviceIDs"`
 	SSHPort        int      `json:"sshPort"`
 	RemoteUser     string   `json:"remoteUser"`
+	Runtime        string   `json:"runtime,omitempty"`
+	Status         string   `json:"status,omitempty"`
+	Error          string   `json:"error,omitempty"`
+
+	// WorkspaceFolder is the host directory mounted into the dev container
+	// at WorkspaceMount.
+	WorkspaceFolder string `json:"workspaceFolder,omitempty"`
+	WorkspaceMount  string `json:"workspaceMount,omitempty"`
+
+	// Shell is the remote user's shell in the dev container and UserEnv the
+	// variables their login environment adds, as found by userEnvProbe.
+	Shell   string   `json:"shell,omitempty"`
+	UserEnv []string `json:"userEnv,omitempty"`
+
+	// Snapshot is the tag of the snapshot the dev container was started
+	// from, if any. Later ups, recreates and repairs start from it too.
+	Snapshot string `json:"snapshot,omitempty"`
+
+	// CreatedAt is when the environment's network namespace was created,
+	// StartedAt when up last brought the environment up.
+	CreatedAt time.Time `json:"createdAt,omitzero"`
+	StartedAt time.Time `json:"startedAt,omitzero"`
+
+	// ConfigFile and ConfigHash identify the devcontainer.json up last
+	// applied; ConfigHash is its config.Fingerprint.
+	ConfigFile string `json:"configFile,omitempty"`
+	ConfigHash string `json:"configHash,omitempty"`
+
+	Containers []ContainerRecord `json:"containers,omitempty"`
+	// Ports are the published ports, host:container.
+	Ports []string `json:"ports,omitempty"`
+	// FeatureDigests hash each configured feature's options, by feature ID.
+	FeatureDigests map[string]string `json:"featureDigests,omitempty"`
+	// Lifecycle records the last run of each lifecycle command, by name
+	// (e.g. "postCreateCommand").
+	Lifecycle map[string]LifecycleRecord `json:"lifecycle,omitempty"`
+}
+
+// ContainerRecord is a container of the environment as up created it.
+type ContainerRecord struct {
+	Name       string `json:"name,omitempty"`
+	Role       string `json:"role"`
+	ID         string `json:"id"`
+	Image      string `json:"image,omitempty"`
+	ImageID    string `json:"imageID,omitempty"`    // content digest of Image when created
+	ConfigHash string `json:"configHash,omitempty"` // hash of the container's spec
+	Stopped    bool   `json:"stopped,omitempty"`    // stopped with 'envclone stop'
+}
+
+// LifecycleRecord is the outcome of a lifecycle command.
+type LifecycleRecord struct {
+	Command  string    `json:"command"`
+	Finished time.Time `json:"finished"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// SetContainer records a container, replacing the record of the container
+// with the same name.
+func (e *Environment) SetContainer(rec ContainerRecord) {
+	for i, c := range e.Containers {
+		if c.Name == rec.Name {
+			e.Containers[i] = rec
+			return
+		}
+	}
+	e.Containers = append(e.Containers, rec)
 }
 
-func stateDir() (string, error) {
+// SetStopped records whether the named container was stopped on purpose.
+func (e *Environment) SetStopped(name string, stopped bool) {
+	for i, c := range e.Containers {
+		if c.Name == name {
+			e.Containers[i].Stopped = stopped
+		}
+	}
+}
+
+// Container returns the record of the named container, if any.
+func (e *Environment) Container(name string) (ContainerRecord, bool) {
+	for _, c := range e.Containers {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return ContainerRecord{}, false
+}
+
+// Failed reports whether the environment was left behind by a failed up.
+func (e *Environment) Failed() bool {
+	return e.Status == StatusFailed
+}
+
+// Dir returns envclone's state directory, creating it if needed.
+func Dir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -27,13 +132,27 @@ func stateDir() (string, error) {
 	return dir, os.MkdirAll(dir, 0o755)
 }
 
+// projectKey identifies a project directory within the state directory.
+func projectKey(projectDir string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(projectDir)))[:12]
+}
+
 func stateFile(projectDir string) (string, error) {
-	dir, err := stateDir()
+	dir, err := Dir()
 	if err != nil {
 		return "", err
 	}
-	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(projectDir)))[:12]
-	return filepath.Join(dir, hash+".json"), nil
+	return filepath.Join(dir, projectKey(projectDir)+".json"), nil
+}
+
+// DataDir returns the directory holding the project's volume snapshots.
+// It is not created.
+func DataDir(projectDir string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "data", projectKey(projectDir)), nil
 }
 
 func Save(projectDir string, env *Environment) error {
@@ -41,11 +160,12 @@ func Save(projectDir string, env *Environment) error {
 	if err != nil {
 		return err
 	}
+	env.SchemaVersion = SchemaVersion
 	data, err := json.MarshalIndent(env, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0o644)
+	return writeFile(path, data)
 }
 
 func Load(projectDir string) (*Environment, error) {
@@ -53,6 +173,41 @@ func Load(projectDir string) (*Environment, error) {
 	if err != nil {
 		return nil, err
 	}
+	return loadFile(path)
+}
+
+// List loads the state of every environment, sorted by project
+// directory. Files that cannot be read are skipped and reported in the
+// error, alongside the environments that could.
+func List() ([]*Environment, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var envs []*Environment
+	var errs []error
+	for _, e := range entries {
+		// Dotfiles are in-progress atomic writes.
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		env, err := loadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		envs = append(envs, env)
+	}
+	sort.Slice(envs, func(i, j int) bool { return envs[i].ProjectDir < envs[j].ProjectDir })
+	return envs, errors.Join(errs...)
+}
+
+func loadFile(path string) (*Environment, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -61,6 +216,9 @@ func Load(projectDir string) (*Environment, error) {
 	if err := json.Unmarshal(data, &env); err != nil {
 		return nil, err
 	}
+	if err := migrate(&env); err != nil {
+		return nil, err
+	}
 	return &env, nil
 }
 
@@ -71,3 +229,116 @@ func Remove(projectDir string) error {
 	}
 	return os.Remove(path)
 }
+
+// projectPaths returns the files and directories that hold what envclone
+// keeps for a project, whether or not they exist: the state file, logs,
+// data snapshots, snapshot records and lock file, in that order.
+func projectPaths(projectDir string) ([]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	key := projectKey(projectDir)
+	return []string{
+		filepath.Join(dir, key+".json"),
+		filepath.Join(dir, "logs", key),
+		filepath.Join(dir, "data", key),
+		filepath.Join(dir, "snapshots", key+".json"),
+		filepath.Join(dir, "locks", key+".lock"),
+	}, nil
+}
+
+// Usage is the disk space envclone's own files take for a project.
+type Usage struct {
+	State int64 `json:"state"` // state, snapshot records and lock file
+	Logs  int64 `json:"logs"`
+	Data  int64 `json:"data"` // data snapshots
+}
+
+// Total returns the space all of them take.
+func (u Usage) Total() int64 {
+	return u.State + u.Logs + u.Data
+}
+
+// DiskUsage returns the space taken by everything envclone keeps for the
+// project.
+func DiskUsage(projectDir string) (Usage, error) {
+	paths, err := projectPaths(projectDir)
+	if err != nil {
+		return Usage{}, err
+	}
+	var u Usage
+	for i, path := range paths {
+		size, err := dirSize(path)
+		if err != nil {
+			return u, err
+		}
+		switch i {
+		case 1:
+			u.Logs += size
+		case 2:
+			u.Data += size
+		default:
+			u.State += size
+		}
+	}
+	return u, nil
+}
+
+// dirSize returns the total size of the files under path, or of path
+// itself if it is a file. A missing path takes no space.
+func dirSize(path string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return total, err
+}
+
+// Purge removes everything envclone keeps for the project, including its
+// data snapshots.
+func Purge(projectDir string) error {
+	paths, err := projectPaths(projectDir)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LifecycleLog returns the path of the file that captures the output of
+// the project's lifecycle commands during up, creating its directory.
+func LifecycleLog(projectDir string) (string, error) {
+	return logFile(projectDir, "lifecycle.log")
+}
+
+// OperationLog returns the path of the project's operation log, which
+// records the commands envclone ran on the project's behalf.
+func OperationLog(projectDir string) (string, error) {
+	return logFile(projectDir, "operations.log")
+}
+
+func logFile(projectDir, name string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	logDir := filepath.Join(dir, "logs", projectKey(projectDir))
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(logDir, name), nil
+}
@@ -0,0 +1,67 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for _, dir := range []string{"/src/web", "/src/api"} {
+		if err := Save(dir, &Environment{ProjectName: filepath.Base(dir), ProjectDir: dir}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeState(t, "/src/broken", `{`)
+
+	envs, err := List()
+	if err == nil {
+		t.Error("List succeeded with a corrupt state file")
+	}
+	var dirs []string
+	for _, env := range envs {
+		dirs = append(dirs, env.ProjectDir)
+	}
+	if got := strings.Join(dirs, " "); got != "/src/api /src/web" {
+		t.Errorf("List = %s, want /src/api /src/web", got)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := Save("/src/gone", &Environment{ProjectName: "gone"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := DataDir("/src/gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(data, "postgres", "seeded"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(data, "postgres", "seeded", "pgdata.tar.gz"), make([]byte, 4096), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save("/src/kept", &Environment{ProjectName: "kept"}); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := DiskUsage("/src/gone")
+	if err != nil || usage.Data != 4096 || usage.State == 0 || usage.Total() != usage.State+4096 {
+		t.Errorf("DiskUsage = %+v, %v; want the state file and snapshot counted", usage, err)
+	}
+	if err := Purge("/src/gone"); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if _, err := Load("/src/gone"); !os.IsNotExist(err) {
+		t.Errorf("Load after Purge = %v, want not found", err)
+	}
+	if _, err := os.Stat(data); !os.IsNotExist(err) {
+		t.Errorf("data snapshots left after Purge: %v", err)
+	}
+	if _, err := Load("/src/kept"); err != nil {
+		t.Errorf("other project's state: %v", err)
+	}
+}
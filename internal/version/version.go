@@ -0,0 +1,20 @@
+// Package version reports which envclone is running.
+package version
+
+import "runtime/debug"
+
+// Version is set at build time with
+// -ldflags "-X github.com/matoval/envclone/internal/version.Version=v1.2.3".
+var Version = ""
+
+// String returns the envclone version: Version if set, otherwise the
+// module version go install recorded, otherwise "dev".
+func String() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}